@@ -0,0 +1,377 @@
+package api
+
+import (
+	"bytes"
+	db "db"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+const (
+	maxBulkRows = 1000
+
+	bulkStatusCreated    = "created"
+	bulkStatusPatched    = "patched"
+	bulkStatusDeleted    = "deleted"
+	bulkStatusInvalid    = "invalid"
+	bulkStatusFailed     = "failed"
+	bulkStatusSkipped    = "skipped"
+	bulkStatusRolledBack = "rolled_back"
+)
+
+// BulkOperation is one item of POST /people/bulk. Create takes person; patch
+// takes id and a merge patch; delete takes id. version, if set, must match
+// the person's current version.
+type BulkOperation struct {
+	Op      string           `json:"op" enums:"create,patch,delete" example:"patch"`
+	ID      int              `json:"id,omitempty" example:"42"`
+	Version int              `json:"version,omitempty"`
+	Person  *PersonReq       `json:"person,omitempty"`
+	Patch   *json.RawMessage `json:"patch,omitempty" swaggertype:"object"`
+}
+
+type BulkRequest struct {
+	Operations []BulkOperation `json:"operations"`
+}
+
+type BulkItemResult struct {
+	Index  int        `json:"index"`
+	Op     string     `json:"op" example:"patch"`
+	ID     int        `json:"id,omitempty"`
+	Status string     `json:"status" example:"patched"`
+	Errors []string   `json:"errors,omitempty"`
+	Person *db.Person `json:"person,omitempty"`
+}
+
+// BulkReport is the per-item result of a bulk write. Matched is set for
+// filtered writes.
+type BulkReport struct {
+	DryRun    bool             `json:"dry_run"`
+	Committed bool             `json:"committed"`
+	Matched   *int             `json:"matched,omitempty"`
+	Total     int              `json:"total"`
+	Items     []BulkItemResult `json:"items"`
+}
+
+// @Summary Массовые операции
+// @Description Выполняет список операций create, patch (JSON Merge Patch) и delete в одной транзакции: при ошибке любой операции не применяется ни одна. Результат — отчет по каждой операции.
+// @Description При dry_run=true операции выполняются и откатываются, отчет показывает, что было бы сделано. Не более 1000 операций, каждый id — не более одного раза
+// @Tags people
+// @Accept  json
+// @Produce  json
+// @Param operations body BulkRequest true "Операции"
+// @Param dry_run query bool false "Только показать результат, не сохраняя изменения"
+// @Success 200 {object} BulkReport
+// @Failure 400 {object} Problem
+// @Failure 404 {object} BulkReport "Запись не найдена"
+// @Failure 409 {object} BulkReport "Запись изменилась"
+// @Failure 413 {object} Problem
+// @Failure 422 {object} BulkReport
+// @Failure 500 {object} Problem
+// @Router /people/bulk [post]
+func (s *APIServer) handleBulkPeople(w http.ResponseWriter, r *http.Request) error {
+	var req BulkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return badRequest("invalid bulk request")
+	}
+	if len(req.Operations) > maxBulkRows {
+		return requestError(http.StatusRequestEntityTooLarge, codeTooLarge, fmt.Sprintf("bulk is limited to %d operations", maxBulkRows))
+	}
+
+	report := BulkReport{
+		DryRun: r.URL.Query().Get("dry_run") == "true",
+		Total:  len(req.Operations),
+		Items:  make([]BulkItemResult, len(req.Operations)),
+	}
+	ops := make([]db.BulkOp, len(req.Operations))
+
+	var names []string
+	seen := make(map[int]bool)
+	for i, op := range req.Operations {
+		item := &report.Items[i]
+		*item = BulkItemResult{Index: i, Op: op.Op, ID: op.ID}
+		var errs []string
+		switch op.Op {
+		case string(db.BulkCreate):
+			if op.Person == nil {
+				errs = []string{"person is required"}
+				break
+			}
+			errs = fieldErrorMessages(validatePersonReq(*op.Person))
+			names = append(names, op.Person.Name)
+		case string(db.BulkPatch), string(db.BulkDelete):
+			switch {
+			case op.ID < 1:
+				errs = []string{"id is required"}
+			case seen[op.ID]:
+				errs = []string{fmt.Sprintf("id %d appears more than once", op.ID)}
+			case op.Op == string(db.BulkPatch) && op.Patch == nil:
+				errs = []string{"patch is required"}
+			}
+			seen[op.ID] = true
+		default:
+			errs = []string{fmt.Sprintf("op %q is not supported", op.Op)}
+		}
+		if len(errs) > 0 {
+			item.Status = bulkStatusInvalid
+			item.Errors = errs
+		}
+	}
+
+	enriched := enrichNames(names)
+	for i, op := range req.Operations {
+		item := &report.Items[i]
+		if item.Status != "" {
+			continue
+		}
+		switch op.Op {
+		case string(db.BulkCreate):
+			res := enriched[enrichCacheKey(op.Person.Name)]
+			if res.err != nil || !res.complete() {
+				item.Status = bulkStatusInvalid
+				item.Errors = []string{"enrichment failed: " + errIncompleteEnrichment.Error()}
+				if res.err != nil {
+					item.Errors = []string{"enrichment failed: " + res.err.Error()}
+				}
+				continue
+			}
+			ops[i] = db.BulkOp{Action: db.BulkCreate, Person: res.apply(*op.Person)}
+		case string(db.BulkPatch):
+			patch, errs, err := decodeMergePatch(bytes.NewReader(*op.Patch))
+			if err != nil {
+				item.Status = bulkStatusInvalid
+				item.Errors = []string{err.Error()}
+				continue
+			}
+			if len(errs) > 0 {
+				item.Status = bulkStatusInvalid
+				item.Errors = fieldErrorMessages(errs)
+				continue
+			}
+			current, err := s.dbStorage.GetPerson(op.ID)
+			if errors.Is(err, db.ErrPersonNotFound) || errors.Is(err, db.ErrPersonMerged) {
+				item.Status = bulkStatusInvalid
+				item.Errors = []string{fmt.Sprintf("person with id %d not found", op.ID)}
+				continue
+			}
+			if err != nil {
+				return internalError(err)
+			}
+			if errs := validatePerson(patch.Apply(current)); len(errs) > 0 {
+				item.Status = bulkStatusInvalid
+				item.Errors = fieldErrorMessages(errs)
+				continue
+			}
+			version := op.Version
+			if version == db.AnyVersion {
+				version = current.Version
+			}
+			ops[i] = db.BulkOp{Action: db.BulkPatch, ID: op.ID, Version: version, Patch: patch}
+		case string(db.BulkDelete):
+			ops[i] = db.BulkOp{Action: db.BulkDelete, ID: op.ID, Version: op.Version}
+		}
+	}
+
+	for _, item := range report.Items {
+		if item.Status == bulkStatusInvalid {
+			markBulkItems(&report, bulkStatusSkipped, "bulk aborted: other operations are invalid")
+			return WriteJson(w, http.StatusUnprocessableEntity, report)
+		}
+	}
+
+	return s.execBulk(w, r, ops, &report)
+}
+
+// @Summary Массовое изменение по фильтру
+// @Description Применяет JSON Merge Patch ко всем записям, подходящим под фильтры списка (filter[field][op], fname, surname и т.д.), в одной транзакции.
+// @Description Сначала нужно выполнить запрос с dry_run=true и получить количество затрагиваемых записей (matched), затем повторить его с expected_count=matched. Затрагивается не более 1000 записей
+// @Tags people
+// @Accept  application/merge-patch+json
+// @Accept  json
+// @Produce  json
+// @Param filter[field][op] query string true "Типизированный фильтр, как в GET /people"
+// @Param dry_run query bool false "Только показать затрагиваемые записи"
+// @Param expected_count query int false "Количество записей из dry_run; обязательно без dry_run"
+// @Param person body PersonMergePatch true "Изменяемые поля"
+// @Success 200 {object} BulkReport
+// @Failure 400 {object} Problem
+// @Failure 409 {object} Problem "Количество записей изменилось после dry_run или запись изменилась"
+// @Failure 413 {object} Problem
+// @Failure 415 {object} Problem
+// @Failure 422 {object} BulkReport
+// @Failure 428 {object} Problem "Не передан expected_count"
+// @Failure 500 {object} Problem
+// @Router /people [patch]
+func (s *APIServer) handlePatchPeopleByFilter(w http.ResponseWriter, r *http.Request) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != mergePatchContentType && mediaType != "application/json" {
+		return requestError(http.StatusUnsupportedMediaType, codeUnsupportedMedia, "expected "+mergePatchContentType)
+	}
+	patch, errs, err := decodeMergePatch(r.Body)
+	if err != nil {
+		return badRequest("%s", err)
+	}
+	if len(errs) > 0 {
+		return validationError("invalid patch", errs)
+	}
+
+	people, report, err := s.bulkTargets(r)
+	if err != nil {
+		return err
+	}
+
+	ops := make([]db.BulkOp, len(people))
+	invalid := false
+	for i, p := range people {
+		ops[i] = db.BulkOp{Action: db.BulkPatch, ID: p.ID, Version: p.Version, Patch: patch}
+		if errs := validatePerson(patch.Apply(p)); len(errs) > 0 {
+			report.Items[i].Status = bulkStatusInvalid
+			report.Items[i].Errors = fieldErrorMessages(errs)
+			invalid = true
+		}
+	}
+	if invalid {
+		markBulkItems(&report, bulkStatusSkipped, "bulk aborted: other people would become invalid")
+		return WriteJson(w, http.StatusUnprocessableEntity, report)
+	}
+
+	return s.execBulk(w, r, ops, &report)
+}
+
+// @Summary Массовое удаление по фильтру
+// @Description Помечает удаленными все записи, подходящие под фильтры списка, в одной транзакции.
+// @Description Сначала нужно выполнить запрос с dry_run=true и получить количество затрагиваемых записей (matched), затем повторить его с expected_count=matched. Затрагивается не более 1000 записей
+// @Tags people
+// @Produce  json
+// @Param filter[field][op] query string true "Типизированный фильтр, как в GET /people"
+// @Param dry_run query bool false "Только показать затрагиваемые записи"
+// @Param expected_count query int false "Количество записей из dry_run; обязательно без dry_run"
+// @Success 200 {object} BulkReport
+// @Failure 400 {object} Problem
+// @Failure 409 {object} Problem "Количество записей изменилось после dry_run или запись изменилась"
+// @Failure 413 {object} Problem
+// @Failure 428 {object} Problem "Не передан expected_count"
+// @Failure 500 {object} Problem
+// @Router /people [delete]
+func (s *APIServer) handleDeletePeopleByFilter(w http.ResponseWriter, r *http.Request) error {
+	people, report, err := s.bulkTargets(r)
+	if err != nil {
+		return err
+	}
+
+	ops := make([]db.BulkOp, len(people))
+	for i, p := range people {
+		ops[i] = db.BulkOp{Action: db.BulkDelete, ID: p.ID, Version: p.Version}
+	}
+	return s.execBulk(w, r, ops, &report)
+}
+
+// bulkTargets lists the people a filtered bulk write applies to and starts
+// its report. Outside a dry run the caller must confirm the number of people
+// previewed with expected_count.
+func (s *APIServer) bulkTargets(r *http.Request) ([]db.Person, BulkReport, error) {
+	query := r.URL.Query()
+	report := BulkReport{DryRun: query.Get("dry_run") == "true"}
+
+	filter, err := parsePeopleFilter(query)
+	if err != nil {
+		return nil, report, err
+	}
+	if len(filter.Conditions) == 0 {
+		return nil, report, badRequest("a filter is required")
+	}
+
+	expected := -1
+	if raw := query.Get("expected_count"); raw != "" {
+		if expected, err = strconv.Atoi(raw); err != nil || expected < 0 {
+			return nil, report, badRequest("expected_count must be a non-negative integer")
+		}
+	} else if !report.DryRun {
+		return nil, report, requestError(http.StatusPreconditionRequired, codePreconditionRequired,
+			"run with dry_run=true first and pass the matched count as expected_count")
+	}
+
+	people, err := s.dbStorage.ListBulkTargets(filter, maxBulkRows+1)
+	if err != nil {
+		return nil, report, err
+	}
+	if len(people) > maxBulkRows {
+		return nil, report, requestError(http.StatusRequestEntityTooLarge, codeTooLarge,
+			fmt.Sprintf("filter matches more than %d people, narrow it down", maxBulkRows))
+	}
+	if !report.DryRun && len(people) != expected {
+		return nil, report, conflict(codeConflict, fmt.Sprintf("filter matches %d people, expected %d", len(people), expected))
+	}
+
+	matched := len(people)
+	report.Matched = &matched
+	report.Total = matched
+	report.Items = make([]BulkItemResult, matched)
+	op := string(db.BulkPatch)
+	if r.Method == http.MethodDelete {
+		op = string(db.BulkDelete)
+	}
+	for i, p := range people {
+		report.Items[i] = BulkItemResult{Index: i, Op: op, ID: p.ID}
+	}
+	return people, report, nil
+}
+
+// execBulk runs validated ops and writes the report.
+func (s *APIServer) execBulk(w http.ResponseWriter, r *http.Request, ops []db.BulkOp, report *BulkReport) error {
+	results, err := s.dbStorage.ExecBulk(ops, report.DryRun, changeFor(r, db.SourceBulk))
+	if err != nil {
+		var opErr *db.BulkOpError
+		if !errors.As(err, &opErr) {
+			return internalError(err)
+		}
+
+		status := http.StatusInternalServerError
+		msg := "internal server error"
+		switch {
+		case errors.Is(err, db.ErrPersonNotFound):
+			status, msg = http.StatusNotFound, fmt.Sprintf("person with id %d not found", ops[opErr.Index].ID)
+		case errors.Is(err, db.ErrVersionMismatch):
+			status, msg = http.StatusConflict, fmt.Sprintf("person with id %d was modified concurrently", ops[opErr.Index].ID)
+		default:
+			log.Printf("err at bulk: %s", err)
+		}
+		markBulkItems(report, bulkStatusRolledBack, "bulk rolled back: another operation failed")
+		report.Items[opErr.Index].Status = bulkStatusFailed
+		report.Items[opErr.Index].Errors = []string{msg}
+		return WriteJson(w, status, report)
+	}
+
+	for i, op := range ops {
+		item := &report.Items[i]
+		switch op.Action {
+		case db.BulkCreate:
+			item.Status = bulkStatusCreated
+		case db.BulkPatch:
+			item.Status = bulkStatusPatched
+		case db.BulkDelete:
+			item.Status = bulkStatusDeleted
+		}
+		item.ID = results[i].ID
+		if op.Action != db.BulkDelete {
+			item.Person = &results[i]
+		}
+	}
+	report.Committed = !report.DryRun
+	return WriteJson(w, http.StatusOK, *report)
+}
+
+// markBulkItems sets every item without a status.
+func markBulkItems(report *BulkReport, status, msg string) {
+	for i := range report.Items {
+		if report.Items[i].Status == "" {
+			report.Items[i].Status = status
+			report.Items[i].Errors = []string{msg}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// stubProviders points the enrichment providers at a test server for the
+// duration of the test. Each handler answers for one provider.
+func stubProviders(t *testing.T, agify, genderize, nationalize http.HandlerFunc) {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/agify/", agify)
+	mux.HandleFunc("/genderize/", genderize)
+	mux.HandleFunc("/nationalize/", nationalize)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	saved := []string{agifyAPI, genderizeAPI, nationalizeAPI}
+	savedExt := ExtAPIs
+	agifyAPI, genderizeAPI, nationalizeAPI = srv.URL+"/agify/", srv.URL+"/genderize/", srv.URL+"/nationalize/"
+	ExtAPIs = []string{nationalizeAPI, genderizeAPI, agifyAPI}
+	t.Cleanup(func() {
+		agifyAPI, genderizeAPI, nationalizeAPI = saved[0], saved[1], saved[2]
+		ExtAPIs = savedExt
+	})
+}
+
+func respond(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}
+}
+
+func TestBulkCreateReportsFailedEnrichment(t *testing.T) {
+	okAge := respond(http.StatusOK, `{"age": 40}`)
+	okGender := respond(http.StatusOK, `{"gender": "male", "probability": 0.9}`)
+	okCountry := respond(http.StatusOK, `{"country": [{"country_id": "RU", "probability": 0.8}]}`)
+
+	tests := []struct {
+		name                          string
+		person                        string
+		agify, genderize, nationalize http.HandlerFunc
+		wantError                     string
+	}{
+		{
+			name:        "unknown age",
+			person:      "Zqwxagenull",
+			agify:       respond(http.StatusOK, `{"age": null}`),
+			genderize:   okGender,
+			nationalize: okCountry,
+			wantError:   "incomplete data",
+		},
+		{
+			name:        "unknown gender",
+			person:      "Zqwxgendernull",
+			agify:       okAge,
+			genderize:   respond(http.StatusOK, `{"gender": null, "probability": null}`),
+			nationalize: okCountry,
+			wantError:   "incomplete data",
+		},
+		{
+			name:        "rate limited",
+			person:      "Zqwxratelimit",
+			agify:       okAge,
+			genderize:   respond(http.StatusTooManyRequests, `{"error": "Request limit reached"}`),
+			nationalize: okCountry,
+			wantError:   "unexpected status 429",
+		},
+		{
+			name:        "malformed country list",
+			person:      "Zqwxcountry",
+			agify:       okAge,
+			genderize:   okGender,
+			nationalize: respond(http.StatusOK, `{"country": [null, {"country_id": 7}]}`),
+			wantError:   "incomplete data",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubProviders(t, tt.agify, tt.genderize, tt.nationalize)
+			stubCountries(t, "RU")
+			discardLogs(t)
+
+			body := `{"operations": [{"op": "create", "person": {"name": "` + tt.person + `", "surname": "Ivanov"}}]}`
+			req := httptest.NewRequest(http.MethodPost, "/people/bulk", strings.NewReader(body))
+			rec := httptest.NewRecorder()
+			makeHTTPHandleFunc((&APIServer{}).handleBulkPeople)(rec, req)
+
+			if rec.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, http.StatusUnprocessableEntity, rec.Body)
+			}
+			var report BulkReport
+			if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+				t.Fatalf("decode report: %v", err)
+			}
+			if report.Committed || len(report.Items) != 1 {
+				t.Fatalf("report = %+v, want one uncommitted item", report)
+			}
+			item := report.Items[0]
+			if item.Status != bulkStatusInvalid || len(item.Errors) != 1 || !strings.Contains(item.Errors[0], tt.wantError) {
+				t.Errorf("item = %+v, want %s with %q", item, bulkStatusInvalid, tt.wantError)
+			}
+		})
+	}
+}
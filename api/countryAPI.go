@@ -0,0 +1,124 @@
+package api
+
+import (
+	db "db"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// countryCache holds em_countries, which only changes with migrations, so it
+// is read once per process: at startup, as nationality validation depends
+// on it, or on first use. A failed read is retried on the next use.
+var countryCache struct {
+	mu        sync.Mutex
+	countries []db.Country
+	byAlpha2  map[string]db.Country
+}
+
+func (s *APIServer) countries() ([]db.Country, map[string]db.Country, error) {
+	countryCache.mu.Lock()
+	defer countryCache.mu.Unlock()
+
+	if countryCache.byAlpha2 == nil {
+		countries, err := s.dbStorage.ListCountries()
+		if err != nil {
+			return nil, nil, err
+		}
+		setCountries(countries)
+	}
+	return countryCache.countries, countryCache.byAlpha2, nil
+}
+
+// setCountries fills the cache; the caller holds countryCache.mu.
+func setCountries(countries []db.Country) {
+	byAlpha2 := make(map[string]db.Country, len(countries))
+	for _, c := range countries {
+		byAlpha2[c.Alpha2] = c
+	}
+	countryCache.countries, countryCache.byAlpha2 = countries, byAlpha2
+}
+
+// knownCountry reports whether alpha2 is in em_countries. Before the cache
+// is loaded no code is known.
+func knownCountry(alpha2 string) bool {
+	countryCache.mu.Lock()
+	defer countryCache.mu.Unlock()
+	_, ok := countryCache.byAlpha2[alpha2]
+	return ok
+}
+
+// @Summary Справочник стран
+// @Description Страны ISO 3166-1, на которые ссылается национальность: коды alpha-2, alpha-3, числовой код и названия на английском и русском
+// @Tags countries
+// @Produce  json
+// @Success 200 {array} db.Country
+// @Failure 500 {object} Problem
+// @Router /countries [get]
+func (s *APIServer) handleGetCountries(w http.ResponseWriter, r *http.Request) error {
+	countries, _, err := s.countries()
+	if err != nil {
+		return internalError(err)
+	}
+	return WriteJson(w, http.StatusOK, countries)
+}
+
+// PersonView is a person as read through the API: with expand=nationality
+// the nationality is the country object rather than its code.
+type PersonView struct {
+	db.Person
+	Nationality nationalityView `json:"nationality" swaggertype:"string" example:"RU"`
+}
+
+// nationalityView renders as the code, or as the country when expanded. An
+// expanded nationality without a country is null.
+type nationalityView struct {
+	code     string
+	expanded bool
+	country  *db.Country
+}
+
+func (n nationalityView) MarshalJSON() ([]byte, error) {
+	if n.expanded {
+		return json.Marshal(n.country)
+	}
+	return json.Marshal(n.code)
+}
+
+// parseExpand reads the comma-separated expand parameter and reports whether
+// the nationality is to be expanded, the only expansion there is.
+func parseExpand(r *http.Request) (bool, error) {
+	nationality := false
+	for _, field := range strings.Split(r.URL.Query().Get("expand"), ",") {
+		switch strings.TrimSpace(field) {
+		case "":
+		case "nationality":
+			nationality = true
+		default:
+			return false, badRequest("cannot expand %q", field)
+		}
+	}
+	return nationality, nil
+}
+
+// personViews wraps people for a response, expanding their nationality if
+// asked to.
+func (s *APIServer) personViews(people []db.Person, expandNationality bool) ([]PersonView, error) {
+	var byAlpha2 map[string]db.Country
+	if expandNationality {
+		var err error
+		if _, byAlpha2, err = s.countries(); err != nil {
+			return nil, err
+		}
+	}
+
+	views := make([]PersonView, len(people))
+	for i, p := range people {
+		views[i] = PersonView{Person: p, Nationality: nationalityView{code: p.Nationality, expanded: expandNationality}}
+		if c, ok := byAlpha2[p.Nationality]; ok {
+			views[i].Nationality.country = &c
+		}
+	}
+	return views, nil
+}
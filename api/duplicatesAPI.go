@@ -0,0 +1,63 @@
+package api
+
+import (
+	db "db"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+const (
+	onDuplicateReject = "reject"
+	onDuplicateReturn = "return"
+	onDuplicateCreate = "create"
+)
+
+// duplicateError rejects a create that likely duplicates the candidates.
+func duplicateError(candidates []db.DuplicateCandidate) *APIError {
+	ids := make([]int, len(candidates))
+	for i, c := range candidates {
+		ids[i] = c.ID
+	}
+	err := conflict(codeDuplicate, "likely duplicate of existing people")
+	err.Candidates = ids
+	return err
+}
+
+// @Summary Вероятные дубликаты человека
+// @Description Поиск записей, похожих на данного человека: совпадение нормализованного ФИО или высокая схожесть полного имени
+// @Tags people
+// @Accept  json
+// @Produce  json
+// @Param id path int true "ID человека"
+// @Success 200 {array} db.DuplicateCandidate
+// @Success 301 "Запись объединена с другой"
+// @Failure 400 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /people/{id}/duplicates [get]
+func (s *APIServer) handleGetPersonDuplicates(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return badRequest("invalid id")
+	}
+
+	person, err := s.dbStorage.GetPerson(id)
+	if err != nil {
+		if errors.Is(err, db.ErrPersonNotFound) {
+			return personNotFound(id)
+		}
+		var merged *db.MergedError
+		if errors.As(err, &merged) {
+			return writeMergedRedirect(w, r, merged, "/duplicates")
+		}
+		return internalError(err)
+	}
+
+	candidates, err := s.dbStorage.FindDuplicates(person.Name, person.Surname, person.Patronymic, person.ID)
+	if err != nil {
+		return internalError(err)
+	}
+
+	return WriteJson(w, http.StatusOK, candidates)
+}
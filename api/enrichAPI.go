@@ -0,0 +1,189 @@
+package api
+
+import (
+	db "db"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	enrichCacheTTL        = 24 * time.Hour
+	enrichBatchWorkers    = 8
+	enrichCacheMaxEntries = 100000
+)
+
+type enrichment struct {
+	Age                    int
+	Gender                 string
+	GenderProbability      float64
+	Nationality            string
+	NationalityProbability float64
+}
+
+// errIncompleteEnrichment is returned when the providers answered but left
+// out a value a person needs, e.g. an age of null for a rare name.
+var errIncompleteEnrichment = errors.New("external APIs returned incomplete data")
+
+func (e enrichment) complete() bool {
+	return e.Age > 0 && e.Gender != "" && e.Nationality != ""
+}
+
+type cachedEnrichment struct {
+	enrichment
+	expires time.Time
+}
+
+// enrichmentCache keeps provider results per name so repeated names, which
+// are common in bulk imports, hit the external APIs only once.
+type enrichmentCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cachedEnrichment
+}
+
+var enrichCache = &enrichmentCache{ttl: enrichCacheTTL, entries: make(map[string]cachedEnrichment)}
+
+// enrichCacheKey matches names the way lookups do, so "ЁЛКИН" and " ёлкин"
+// share an entry.
+func enrichCacheKey(name string) string {
+	return db.NameLookupKey(name)
+}
+
+func (c *enrichmentCache) get(name string) (enrichment, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := enrichCacheKey(name)
+	e, ok := c.entries[key]
+	if !ok {
+		return enrichment{}, false
+	}
+	if time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return enrichment{}, false
+	}
+	return e.enrichment, true
+}
+
+func (c *enrichmentCache) put(name string, e enrichment) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= enrichCacheMaxEntries {
+		c.entries = make(map[string]cachedEnrichment)
+	}
+	c.entries[enrichCacheKey(name)] = cachedEnrichment{enrichment: e, expires: time.Now().Add(c.ttl)}
+}
+
+// enrichName returns provider data for name, consulting the cache first.
+// Only complete results are cached so transient provider failures are retried.
+func enrichName(name string) (enrichment, error) {
+	if e, ok := enrichCache.get(name); ok {
+		return e, nil
+	}
+
+	processed, err := ProcessExtAPIs(FetchAPIS(name))
+	if err != nil {
+		return enrichment{}, fmt.Errorf("failed to enrich data: %w", err)
+	}
+
+	var e enrichment
+	e.Age, _ = processed["age"].(int)
+	e.Gender, _ = processed["gender"].(string)
+	e.GenderProbability, _ = processed["gender_probability"].(float64)
+	e.Nationality, _ = processed["country"].(string)
+	e.NationalityProbability, _ = processed["country_probability"].(float64)
+
+	if e.complete() {
+		enrichCache.put(name, e)
+	}
+	return e, nil
+}
+
+type enrichResult struct {
+	enrichment
+	err error
+}
+
+// enrichNames enriches every distinct name once using a bounded worker pool.
+// The result is keyed by enrichCacheKey.
+func enrichNames(names []string) map[string]enrichResult {
+	unique := make(map[string]string)
+	for _, name := range names {
+		unique[enrichCacheKey(name)] = name
+	}
+
+	jobs := make(chan string)
+	results := make(map[string]enrichResult, len(unique))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for i := 0; i < min(enrichBatchWorkers, len(unique)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range jobs {
+				e, err := enrichName(name)
+				mu.Lock()
+				results[enrichCacheKey(name)] = enrichResult{enrichment: e, err: err}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, name := range unique {
+		jobs <- name
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+func (e enrichment) apply(person PersonReq) db.Person {
+	return db.Person{
+		Name:                   person.Name,
+		Surname:                person.Surname,
+		Patronymic:             person.Patronymic,
+		Age:                    e.Age,
+		Gender:                 e.Gender,
+		GenderProbability:      e.GenderProbability,
+		Nationality:            e.Nationality,
+		NationalityProbability: e.NationalityProbability,
+	}
+}
+
+// enrichPerson enriches a single person. Incomplete provider data is an
+// error, as em_people1 requires an age and a gender.
+func enrichPerson(person PersonReq) (db.Person, error) {
+	e, err := enrichName(person.Name)
+	if err == nil && !e.complete() {
+		err = errIncompleteEnrichment
+	}
+	return e.apply(person), err
+}
+
+// planEnrichUpdate merges the body of PUT /people/enrich/{id} into current:
+// fields left out keep their current values. Names are compared the way
+// they are stored, normalized, so "ivan" for a stored "Ivan" is no change.
+// It returns the merged names, the names that change, and whether the name
+// itself changes and has to be enriched again.
+func planEnrichUpdate(current db.Person, body PersonReq) (PersonReq, db.PersonPatch, bool) {
+	merged := PersonReq{Name: current.Name, Surname: current.Surname, Patronymic: current.Patronymic}
+	var changes db.PersonPatch
+	set := func(field *string, change **string, value, stored string) {
+		if value == "" {
+			return
+		}
+		*field = value
+		if db.NormalizeName(value) != stored {
+			*change = field
+		}
+	}
+	set(&merged.Name, &changes.Name, body.Name, current.Name)
+	set(&merged.Surname, &changes.Surname, body.Surname, current.Surname)
+	set(&merged.Patronymic, &changes.Patronymic, body.Patronymic, current.Patronymic)
+	return merged, changes, changes.Name != nil
+}
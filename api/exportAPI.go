@@ -0,0 +1,367 @@
+package api
+
+import (
+	"archive/zip"
+	"bufio"
+	db "db"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	exportFormatCSV    = "csv"
+	exportFormatNDJSON = "ndjson"
+	exportFormatXLSX   = "xlsx"
+
+	xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+var exportContentTypes = map[string]string{
+	exportFormatCSV:    "text/csv; charset=utf-8",
+	exportFormatNDJSON: "application/x-ndjson",
+	exportFormatXLSX:   xlsxContentType,
+}
+
+var exportColumns = []string{
+	"id", "name", "surname", "patronymic", "age", "gender", "nationality",
+	"gender_probability", "nationality_probability", "enriched_at", "updated_at",
+}
+
+func exportValue(p db.Person, column string) interface{} {
+	switch column {
+	case "id":
+		return p.ID
+	case "name":
+		return p.Name
+	case "surname":
+		return p.Surname
+	case "patronymic":
+		return p.Patronymic
+	case "age":
+		return p.Age
+	case "gender":
+		return p.Gender
+	case "nationality":
+		return p.Nationality
+	case "gender_probability":
+		return p.GenderProbability
+	case "nationality_probability":
+		return p.NationalityProbability
+	case "enriched_at":
+		if p.EnrichedAt == nil {
+			return nil
+		}
+		return p.EnrichedAt.Format(time.RFC3339)
+	case "updated_at":
+		return p.UpdatedAt.Format(time.RFC3339)
+	}
+	return nil
+}
+
+func exportString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// exportWriter writes one export format. Header is called once before any
+// rows when the header row is requested.
+type exportWriter interface {
+	Header(columns []string) error
+	Row(values []interface{}) error
+	Close() error
+}
+
+// @Summary Экспорт людей
+// @Description Выгрузка всех людей, подходящих под фильтры списка, в CSV, NDJSON или XLSX. Формат выбирается параметром format или заголовком Accept. Данные читаются курсором и отдаются потоком
+// @Tags people
+// @Produce  text/csv
+// @Produce  application/x-ndjson
+// @Produce  application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "Формат выгрузки" Enums(csv, ndjson, xlsx)
+// @Param columns query string false "Список колонок через запятую (по умолчанию все)"
+// @Param header query bool false "Добавлять строку заголовка для csv и xlsx (по умолчанию: true)" default(true)
+// @Param fname query string false "Фильтрация по имени (частичное совпадение)"
+// @Param surname query string false "Фильтрация по фамилии (частичное совпадение)"
+// @Param patronymic query string false "Фильтрация по отчество"
+// @Param age query int false "Фильтрация по возрасту"
+// @Param nationality query string false "Фильтрация по национальности (точное совпадение)"
+// @Param gender query string false "Фильтрация по полу (точное совпадение)"
+// @Param filter[field][op] query string false "Типизированный фильтр, как в GET /people"
+// @Param include_deleted query bool false "Показать также удаленные записи (только для администраторов с заголовком X-Admin-Token)"
+// @Success 200 {file} file
+// @Failure 400 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 406 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /people/export [get]
+func (s *APIServer) handleExportPeople(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+
+	format, ok := negotiateExportFormat(query.Get("format"), r.Header.Get("Accept"))
+	if !ok {
+		return requestError(http.StatusNotAcceptable, codeNotAcceptable, "supported formats: csv, ndjson, xlsx")
+	}
+
+	columns, err := parseExportColumns(query.Get("columns"))
+	if err != nil {
+		return badRequest("%s", err)
+	}
+
+	filter, err := parsePeopleFilter(query)
+	if err != nil {
+		return badRequest("%s", err)
+	}
+	if filter.IncludeDeleted, err = s.includeDeleted(r); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", exportContentTypes[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="people.%s"`, format))
+	w.WriteHeader(http.StatusOK)
+
+	buf := bufio.NewWriter(w)
+	var out exportWriter
+	switch format {
+	case exportFormatCSV:
+		out = &csvExportWriter{w: csv.NewWriter(buf)}
+	case exportFormatNDJSON:
+		out = &ndjsonExportWriter{enc: json.NewEncoder(buf), columns: columns}
+	case exportFormatXLSX:
+		out = newXLSXExportWriter(buf)
+	}
+
+	if query.Get("header") != "false" {
+		if err := out.Header(columns); err != nil {
+			log.Printf("err at export: %s", err)
+			return nil
+		}
+	}
+
+	values := make([]interface{}, len(columns))
+	err = s.dbStorage.ExportPeople(r.Context(), filter, func(p db.Person) error {
+		for i, col := range columns {
+			values[i] = exportValue(p, col)
+		}
+		return out.Row(values)
+	})
+	if err == nil {
+		err = out.Close()
+	}
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err != nil {
+		// The status line is already sent, so the only thing left is to log
+		// and cut the stream short.
+		log.Printf("err at export: %s", err)
+	}
+	return nil
+}
+
+func negotiateExportFormat(format, accept string) (string, bool) {
+	if format != "" {
+		_, ok := exportContentTypes[format]
+		return format, ok
+	}
+	if accept == "" {
+		return exportFormatCSV, true
+	}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "text/csv", "*/*", "text/*":
+			return exportFormatCSV, true
+		case "application/x-ndjson":
+			return exportFormatNDJSON, true
+		case xlsxContentType:
+			return exportFormatXLSX, true
+		}
+	}
+	return "", false
+}
+
+func parseExportColumns(param string) ([]string, error) {
+	if param == "" {
+		return exportColumns, nil
+	}
+	known := make(map[string]bool, len(exportColumns))
+	for _, col := range exportColumns {
+		known[col] = true
+	}
+	var columns []string
+	for _, col := range strings.Split(param, ",") {
+		col = strings.TrimSpace(col)
+		if !known[col] {
+			return nil, fmt.Errorf("unknown column %q", col)
+		}
+		columns = append(columns, col)
+	}
+	return columns, nil
+}
+
+type csvExportWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func (c *csvExportWriter) Header(columns []string) error {
+	return c.w.Write(columns)
+}
+
+func (c *csvExportWriter) Row(values []interface{}) error {
+	c.record = c.record[:0]
+	for _, v := range values {
+		c.record = append(c.record, exportString(v))
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvExportWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type ndjsonExportWriter struct {
+	enc     *json.Encoder
+	columns []string
+}
+
+func (n *ndjsonExportWriter) Header([]string) error { return nil }
+
+func (n *ndjsonExportWriter) Row(values []interface{}) error {
+	row := make(map[string]interface{}, len(values))
+	for i, v := range values {
+		row[n.columns[i]] = v
+	}
+	return n.enc.Encode(row)
+}
+
+func (n *ndjsonExportWriter) Close() error { return nil }
+
+// xlsxExportWriter produces a minimal single-sheet workbook. Rows are written
+// straight into the zip stream with inline strings, so no shared string table
+// has to be kept in memory.
+type xlsxExportWriter struct {
+	zw    *zip.Writer
+	sheet io.Writer
+	row   int
+	err   error
+}
+
+const (
+	xlsxContentTypesXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`
+	xlsxRootRelsXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`
+	xlsxWorkbookXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="people" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	xlsxWorkbookRelsXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`
+	xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	xlsxSheetEnd = `</sheetData></worksheet>`
+)
+
+func newXLSXExportWriter(w io.Writer) *xlsxExportWriter {
+	x := &xlsxExportWriter{zw: zip.NewWriter(w)}
+	for _, part := range []struct{ name, body string }{
+		{"[Content_Types].xml", xlsxContentTypesXML},
+		{"_rels/.rels", xlsxRootRelsXML},
+		{"xl/workbook.xml", xlsxWorkbookXML},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRelsXML},
+	} {
+		x.writePart(part.name, part.body)
+	}
+	if x.err == nil {
+		x.sheet, x.err = x.zw.Create("xl/worksheets/sheet1.xml")
+	}
+	if x.err == nil {
+		_, x.err = io.WriteString(x.sheet, xlsxSheetStart)
+	}
+	return x
+}
+
+func (x *xlsxExportWriter) writePart(name, body string) {
+	if x.err != nil {
+		return
+	}
+	var f io.Writer
+	if f, x.err = x.zw.Create(name); x.err == nil {
+		_, x.err = io.WriteString(f, body)
+	}
+}
+
+func (x *xlsxExportWriter) Header(columns []string) error {
+	values := make([]interface{}, len(columns))
+	for i, col := range columns {
+		values[i] = col
+	}
+	return x.Row(values)
+}
+
+func (x *xlsxExportWriter) Row(values []interface{}) error {
+	if x.err != nil {
+		return x.err
+	}
+	x.row++
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<row r="%d">`, x.row)
+	for _, v := range values {
+		switch v := v.(type) {
+		case nil:
+			b.WriteString(`<c/>`)
+		case int, float64:
+			fmt.Fprintf(&b, `<c><v>%s</v></c>`, exportString(v))
+		default:
+			b.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+			xml.EscapeText(&b, []byte(exportString(v)))
+			b.WriteString(`</t></is></c>`)
+		}
+	}
+	b.WriteString(`</row>`)
+
+	_, x.err = io.WriteString(x.sheet, b.String())
+	return x.err
+}
+
+func (x *xlsxExportWriter) Close() error {
+	if x.err != nil {
+		return x.err
+	}
+	if _, err := io.WriteString(x.sheet, xlsxSheetEnd); err != nil {
+		return err
+	}
+	return x.zw.Close()
+}
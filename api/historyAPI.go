@@ -0,0 +1,43 @@
+package api
+
+import (
+	db "db"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// @Summary История изменений человека
+// @Description Журнал изменений записи: старое и новое значение каждого поля, автор (заголовок X-Actor), источник (create, patch, enrichment, merge, import, delete, restore, purge, bulk), ID запроса и время.
+// @Description Записи журнала не изменяются и сохраняются после слияния и удаления
+// @Tags people
+// @Accept  json
+// @Produce  json
+// @Param id path int true "ID человека"
+// @Param page query int false "Номер страницы (по умолчанию: 1)" default(1)
+// @Param entries query int false "Количество записей на странице (по умолчанию: 100)" default(100)
+// @Success 200 {array} db.HistoryEntry
+// @Failure 400 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /people/{id}/history [get]
+func (s *APIServer) handleGetPersonHistory(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return badRequest("invalid id")
+	}
+
+	query := r.URL.Query()
+	page := parseIntPagination(query.Get("page"), 1)
+	entries := min(parseIntPagination(query.Get("entries"), 100), maxPageLimit)
+
+	history, err := s.dbStorage.GetPersonHistory(id, entries, (page-1)*entries)
+	if err != nil {
+		if errors.Is(err, db.ErrPersonNotFound) {
+			return personNotFound(id)
+		}
+		return internalError(err)
+	}
+
+	return WriteJson(w, http.StatusOK, history)
+}
@@ -0,0 +1,262 @@
+package api
+
+import (
+	"bufio"
+	db "db"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	maxImportRows  = 50000
+	maxImportBatch = 1000
+
+	importStatusCreated          = "created"
+	importStatusInvalid          = "invalid"
+	importStatusEnrichmentFailed = "enrichment_failed"
+	importStatusFailed           = "failed"
+	importStatusSkipped          = "skipped"
+)
+
+type ImportRowResult struct {
+	Row    int      `json:"row"`
+	Status string   `json:"status" example:"created"`
+	ID     int      `json:"id,omitempty"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+type ImportReport struct {
+	Atomic    bool              `json:"atomic"`
+	Committed bool              `json:"committed"`
+	Total     int               `json:"total"`
+	Created   int               `json:"created"`
+	Failed    int               `json:"failed"`
+	Rows      []ImportRowResult `json:"rows"`
+}
+
+type importRow struct {
+	row    int
+	person PersonReq
+	err    error
+}
+
+// @Summary Массовый импорт людей
+// @Description Импорт людей из CSV (заголовок name,surname,patronymic) или NDJSON (по одному PersonReq на строку). Каждая строка валидируется и обогащается, результат — отчет по строкам. При atomic=true импорт выполняется целиком или не выполняется вовсе
+// @Tags people
+// @Accept  text/csv
+// @Accept  application/x-ndjson
+// @Produce  json
+// @Param atomic query bool false "Все или ничего: при любой ошибке ни одна запись не создается"
+// @Success 200 {object} ImportReport
+// @Failure 400 {object} Problem
+// @Failure 413 {object} Problem
+// @Failure 415 {object} Problem
+// @Failure 422 {object} ImportReport
+// @Failure 500 {object} Problem
+// @Router /people/import [post]
+func (s *APIServer) handleImportPeople(w http.ResponseWriter, r *http.Request) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	var rows []importRow
+	var err error
+	switch mediaType {
+	case "text/csv":
+		rows, err = readImportCSV(r.Body)
+	case "application/x-ndjson":
+		rows, err = readImportNDJSON(r.Body)
+	default:
+		return requestError(http.StatusUnsupportedMediaType, codeUnsupportedMedia, "expected text/csv or application/x-ndjson")
+	}
+	if err != nil {
+		if errors.Is(err, errImportTooLarge) {
+			return requestError(http.StatusRequestEntityTooLarge, codeTooLarge, err.Error())
+		}
+		return badRequest("%s", err)
+	}
+
+	report := ImportReport{
+		Atomic: r.URL.Query().Get("atomic") == "true",
+		Total:  len(rows),
+		Rows:   make([]ImportRowResult, len(rows)),
+	}
+
+	var names []string
+	for i, row := range rows {
+		report.Rows[i] = ImportRowResult{Row: row.row}
+		if row.err != nil {
+			report.Rows[i].Status = importStatusInvalid
+			report.Rows[i].Errors = []string{row.err.Error()}
+			continue
+		}
+		if errs := validatePersonReq(row.person); len(errs) > 0 {
+			report.Rows[i].Status = importStatusInvalid
+			report.Rows[i].Errors = fieldErrorMessages(errs)
+			continue
+		}
+		names = append(names, row.person.Name)
+	}
+
+	enriched := enrichNames(names)
+
+	var pending []int
+	var people []db.Person
+	for i, row := range rows {
+		if report.Rows[i].Status != "" {
+			continue
+		}
+		res := enriched[enrichCacheKey(row.person.Name)]
+		if res.err != nil || !res.complete() {
+			report.Rows[i].Status = importStatusEnrichmentFailed
+			if res.err != nil {
+				report.Rows[i].Errors = []string{res.err.Error()}
+			} else {
+				report.Rows[i].Errors = []string{errIncompleteEnrichment.Error()}
+			}
+			continue
+		}
+		pending = append(pending, i)
+		people = append(people, res.apply(row.person))
+	}
+
+	change := changeFor(r, db.SourceImport)
+	if report.Atomic {
+		if len(pending) != len(rows) {
+			markImportRows(&report, pending, importStatusSkipped, "import aborted: other rows failed")
+			report.Failed = report.Total
+			return WriteJson(w, http.StatusUnprocessableEntity, report)
+		}
+		ids, err := s.dbStorage.CreatePeople(people, change)
+		if err != nil {
+			return internalError(err)
+		}
+		setImportIDs(&report, pending, ids)
+		report.Committed = true
+		return WriteJson(w, http.StatusOK, report)
+	}
+
+	for start := 0; start < len(pending); start += maxImportBatch {
+		end := min(start+maxImportBatch, len(pending))
+		ids, err := s.dbStorage.CreatePeople(people[start:end], change)
+		if err == nil {
+			setImportIDs(&report, pending[start:end], ids)
+			continue
+		}
+
+		log.Printf("err at import batch, retrying row by row: %s", err)
+		for j := start; j < end; j++ {
+			created, err := s.dbStorage.CreatePerson(people[j], change)
+			if err != nil {
+				markImportRows(&report, pending[j:j+1], importStatusFailed, err.Error())
+				continue
+			}
+			setImportIDs(&report, pending[j:j+1], []int{created.ID})
+		}
+	}
+
+	report.Committed = report.Created > 0
+	report.Failed = report.Total - report.Created
+	return WriteJson(w, http.StatusOK, report)
+}
+
+var errImportTooLarge = fmt.Errorf("import is limited to %d rows", maxImportRows)
+
+func setImportIDs(report *ImportReport, idx []int, ids []int) {
+	for k, i := range idx {
+		report.Rows[i].Status = importStatusCreated
+		report.Rows[i].ID = ids[k]
+		report.Created++
+	}
+}
+
+func markImportRows(report *ImportReport, idx []int, status, msg string) {
+	for _, i := range idx {
+		report.Rows[i].Status = status
+		report.Rows[i].Errors = []string{msg}
+	}
+}
+
+func readImportCSV(body io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, col := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(col, "\ufeff")))] = i
+	}
+	for _, required := range []string{"name", "surname"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("csv header must contain %q column", required)
+		}
+	}
+
+	field := func(record []string, col string) string {
+		i, ok := columns[col]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+
+	var rows []importRow
+	for n := 1; ; n++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if len(rows) >= maxImportRows {
+			return nil, errImportTooLarge
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, fmt.Errorf("failed to read csv: %w", err)
+			}
+			rows = append(rows, importRow{row: n, err: err})
+			continue
+		}
+		rows = append(rows, importRow{row: n, person: PersonReq{
+			Name:       field(record, "name"),
+			Surname:    field(record, "surname"),
+			Patronymic: field(record, "patronymic"),
+		}})
+	}
+	return rows, nil
+}
+
+func readImportNDJSON(body io.Reader) ([]importRow, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var rows []importRow
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if len(rows) >= maxImportRows {
+			return nil, errImportTooLarge
+		}
+		var person PersonReq
+		if err := json.Unmarshal([]byte(line), &person); err != nil {
+			rows = append(rows, importRow{row: n, err: fmt.Errorf("invalid json: %w", err)})
+			continue
+		}
+		rows = append(rows, importRow{row: n, person: person})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ndjson: %w", err)
+	}
+	return rows, nil
+}
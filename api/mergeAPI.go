@@ -0,0 +1,101 @@
+package api
+
+import (
+	db "db"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+type MergeReq struct {
+	SurvivorID int                      `json:"survivor_id" example:"1"`
+	VictimIDs  []int                    `json:"victim_ids" example:"2,3"`
+	Fields     map[string]db.MergeField `json:"fields"`
+}
+
+// @Summary Слияние записей о людях
+// @Description Объединение одной или нескольких записей (victim_ids) с основной записью (survivor_id) в одной транзакции.
+// @Description Для каждого поля (name, surname, patronymic, age, gender, nationality) можно выбрать: survivor — оставить значение основной записи (по умолчанию), victim — взять значение из записи from, re_enrich — заново обогатить (только age, gender, nationality).
+// @Description Слияние записывается в историю, а объединенные ID продолжают отвечать на GET редиректом 301 на основную запись
+// @Tags people
+// @Accept  json
+// @Produce  json
+// @Param merge body MergeReq true "Параметры слияния"
+// @Success 200 {object} db.Person
+// @Failure 400 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 409 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure 502 {object} Problem
+// @Router /people/merge [post]
+func (s *APIServer) handleMergePeople(w http.ResponseWriter, r *http.Request) error {
+	var body MergeReq
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return badRequest("invalid json body")
+	}
+	req := db.MergeRequest{SurvivorID: body.SurvivorID, VictimIDs: body.VictimIDs, Fields: body.Fields}
+
+	if req.NeedsEnrichment() {
+		// The external APIs are slow, so they are asked before the merge
+		// transaction locks anything. MergePeople rejects the result if the
+		// merged name no longer matches.
+		survivor, err := s.dbStorage.GetPerson(req.SurvivorID)
+		if err != nil {
+			return mergeError(err)
+		}
+		victims := make([]db.Person, 0, len(req.VictimIDs))
+		for _, id := range req.VictimIDs {
+			victim, err := s.dbStorage.GetPerson(id)
+			if err != nil {
+				return mergeError(err)
+			}
+			victims = append(victims, victim)
+		}
+		merged, err := db.ResolveMerge(survivor, victims, req)
+		if err != nil {
+			return mergeError(err)
+		}
+		enriched, err := enrichPerson(PersonReq{Name: merged.Name, Surname: merged.Surname, Patronymic: merged.Patronymic})
+		if err != nil {
+			return upstreamError("failed to re-enrich merged person", err)
+		}
+		req.Enriched = &enriched
+	}
+
+	survivor, err := s.dbStorage.MergePeople(req, changeFor(r, db.SourceMerge))
+	if err != nil {
+		return mergeError(err)
+	}
+
+	w.Header().Set("ETag", personETag(survivor))
+	return WriteJson(w, http.StatusOK, survivor)
+}
+
+// mergeError maps the errors of a merge to the API errors.
+func mergeError(err error) error {
+	switch {
+	case errors.Is(err, db.ErrInvalidMerge):
+		return badRequest("%s", err)
+	case errors.Is(err, db.ErrPersonNotFound):
+		return notFound("%s", err)
+	case errors.Is(err, db.ErrPersonMerged), errors.Is(err, db.ErrMergeConflict):
+		return conflict(codeConflict, err.Error())
+	}
+	return err
+}
+
+// writeMergedRedirect answers a read of a merged id with a permanent redirect
+// to the same resource (suffix is the path after the id) of the record it
+// was merged into. The query string is kept.
+func writeMergedRedirect(w http.ResponseWriter, r *http.Request, merged *db.MergedError, suffix string) error {
+	canonical := fmt.Sprintf("/people/%d", merged.SurvivorID)
+	location := canonical + suffix
+	if r.URL.RawQuery != "" {
+		location += "?" + r.URL.RawQuery
+	}
+	w.Header().Set("Location", location)
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="canonical"`, canonical))
+	w.WriteHeader(http.StatusMovedPermanently)
+	return nil
+}
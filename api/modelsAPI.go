@@ -1,16 +1,18 @@
 package api
 
+// PersonReq and PersonEnriched carry the validation rules of a person; see
+// validateStruct. Lengths match the em_people1 columns.
 type PersonReq struct {
-	Name       string `json:"name"`
-	Surname    string `json:"surname"`
-	Patronymic string `json:"patronymic"`
+	Name       string `json:"name" validate:"required,max=50,script"`
+	Surname    string `json:"surname" validate:"required,max=100,script"`
+	Patronymic string `json:"patronymic" validate:"max=100,script"`
 }
 
 type PersonEnriched struct {
 	PersonReq
-	Age         int    `json:"age"`
-	Gender      string `json:"gender"`
-	Nationality string `json:"nationality"`
+	Age         int    `json:"age" validate:"required,min=1,max=199"`
+	Gender      string `json:"gender" validate:"required,oneof=male female"`
+	Nationality string `json:"nationality" validate:"iso3166"`
 }
 
 type AgeResp struct {
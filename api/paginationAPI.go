@@ -0,0 +1,141 @@
+package api
+
+import (
+	"bytes"
+	db "db"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const maxPageLimit = 1000
+
+type CursorPaginatedResults struct {
+	Limit          int          `json:"limit"`
+	Next           string       `json:"next,omitempty"`
+	Prev           string       `json:"prev,omitempty"`
+	EntriesTotal   *int         `json:"entries_total,omitempty"`
+	TotalEstimated bool         `json:"total_estimated,omitempty"`
+	People         []PersonView `json:"people"`
+}
+
+// pageCursor is the opaque position handed to clients: the sort it was
+// issued for and the sort key values of the boundary row.
+type pageCursor struct {
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+}
+
+func encodeCursor(sort db.Sort, p db.Person) string {
+	body, _ := json.Marshal(pageCursor{Sort: sort.String(), Values: sort.Values(p)})
+	return base64.RawURLEncoding.EncodeToString(body)
+}
+
+func decodeCursor(s string, sort db.Sort) ([]interface{}, error) {
+	body, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	var c pageCursor
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&c); err != nil || len(c.Values) != len(sort) {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	if c.Sort != sort.String() {
+		return nil, fmt.Errorf("cursor was issued for sort %q", c.Sort)
+	}
+	return c.Values, nil
+}
+
+func isCursorPagination(query url.Values) bool {
+	return query.Has("after") || query.Has("before") || query.Has("limit")
+}
+
+func (s *APIServer) handleGetPeopleKeyset(w http.ResponseWriter, r *http.Request, filter db.PeopleFilter, sort db.Sort, expandNationality bool) error {
+	query := r.URL.Query()
+
+	limit := parseIntPagination(query.Get("limit"), 10)
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+
+	var keyset db.Keyset
+	if query.Get("after") != "" && query.Get("before") != "" {
+		return badRequest("after and before are mutually exclusive")
+	}
+	var err error
+	if after := query.Get("after"); after != "" {
+		if keyset.After, err = decodeCursor(after, sort); err != nil {
+			return badRequest("%s", err)
+		}
+	}
+	if before := query.Get("before"); before != "" {
+		if keyset.Before, err = decodeCursor(before, sort); err != nil {
+			return badRequest("%s", err)
+		}
+	}
+
+	countMode := db.CountMode(query.Get("count"))
+	if countMode == "" {
+		countMode = db.CountNone
+	}
+	if countMode != db.CountNone && countMode != db.CountExact && countMode != db.CountEstimate {
+		return badRequest("count must be one of none, exact, estimate")
+	}
+
+	people, hasMore, err := s.dbStorage.GetPeopleKeyset(filter, sort, keyset, limit)
+	if err != nil {
+		return internalError(err)
+	}
+	views, err := s.personViews(people, expandNationality)
+	if err != nil {
+		return internalError(err)
+	}
+
+	response := CursorPaginatedResults{Limit: limit, People: views}
+
+	if len(people) > 0 {
+		first, last := people[0], people[len(people)-1]
+		backward := keyset.Before != nil
+		if hasMore || backward {
+			response.Next = pageLink(r, "after", encodeCursor(sort, last), limit)
+		}
+		if (hasMore && backward) || keyset.After != nil {
+			response.Prev = pageLink(r, "before", encodeCursor(sort, first), limit)
+		}
+	}
+
+	if countMode != db.CountNone {
+		total, err := s.dbStorage.CountPeople(filter, countMode)
+		if err != nil {
+			return internalError(err)
+		}
+		response.EntriesTotal = &total
+		response.TotalEstimated = countMode == db.CountEstimate
+	}
+
+	if response.Next != "" {
+		w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="next"`, response.Next))
+	}
+	if response.Prev != "" {
+		w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="prev"`, response.Prev))
+	}
+
+	return WriteJson(w, http.StatusOK, response)
+}
+
+// pageLink rebuilds the request URL with the cursor parameter replaced,
+// keeping filters and limit intact.
+func pageLink(r *http.Request, param, cursor string, limit int) string {
+	query := r.URL.Query()
+	query.Del("after")
+	query.Del("before")
+	query.Del("page")
+	query.Set(param, cursor)
+	query.Set("limit", strconv.Itoa(limit))
+	return r.URL.Path + "?" + query.Encode()
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	db "db"
+	"encoding/base64"
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	sort, err := db.ParseSort("surname,-age")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := db.Person{ID: 42, Surname: "Иванов", Age: 30, UpdatedAt: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)}
+
+	values, err := decodeCursor(encodeCursor(sort, p), sort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{"Иванов", json.Number("30"), json.Number("42")}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("values = %#v, want %#v", values, want)
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	sort, _ := db.ParseSort("surname")
+	other, _ := db.ParseSort("-age")
+	cursor := encodeCursor(sort, db.Person{ID: 1, Surname: "Иванов"})
+	forge := func(body string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(body))
+	}
+
+	tests := map[string]string{
+		"not base64":          "%%%",
+		"not json":            forge("surname"),
+		"too few values":      forge(`{"s":"surname,id","v":["Иванов"]}`),
+		"too many values":     forge(`{"s":"surname,id","v":["Иванов",1,2]}`),
+		"other sort":          encodeCursor(other, db.Person{ID: 1, Age: 3}),
+		"sort field mismatch": forge(`{"s":"name,id","v":["Иван",1]}`),
+	}
+	for name, c := range tests {
+		if _, err := decodeCursor(c, sort); err == nil {
+			t.Errorf("%s: cursor accepted", name)
+		}
+	}
+	if _, err := decodeCursor(cursor, sort); err != nil {
+		t.Errorf("valid cursor rejected: %v", err)
+	}
+}
+
+func TestPageLinkKeepsFilters(t *testing.T) {
+	r := httptest.NewRequest("GET", "/people?filter[age][gte]=18&expand=nationality&after=old&limit=5", nil)
+	link, err := url.Parse(pageLink(r, "before", "cur", 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := link.Query()
+	if q.Get("before") != "cur" || q.Has("after") || q.Get("filter[age][gte]") != "18" || q.Get("expand") != "nationality" || q.Get("limit") != "5" {
+		t.Errorf("link = %s", link)
+	}
+}
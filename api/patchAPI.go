@@ -0,0 +1,172 @@
+package api
+
+import (
+	"bytes"
+	db "db"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+const mergePatchContentType = "application/merge-patch+json"
+
+// PersonMergePatch documents the PATCH body: every field is optional and
+// null clears it.
+type PersonMergePatch struct {
+	Name        *string `json:"name,omitempty"`
+	Surname     *string `json:"surname,omitempty"`
+	Patronymic  *string `json:"patronymic,omitempty"`
+	Age         *int    `json:"age,omitempty"`
+	Gender      *string `json:"gender,omitempty"`
+	Nationality *string `json:"nationality,omitempty"`
+}
+
+// decodeMergePatch reads an RFC 7396 merge patch. Only the fields present in
+// the document end up in the patch; null clears a field, which the resulting
+// record's validation may then reject. Malformed JSON is an error, unknown
+// fields and wrong types are reported as validation errors.
+func decodeMergePatch(body io.Reader) (db.PersonPatch, []FieldError, error) {
+	var patch db.PersonPatch
+
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return patch, nil, fmt.Errorf("failed to read body: %w", err)
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &doc); err != nil || doc == nil {
+		return patch, nil, fmt.Errorf("merge patch must be a JSON object")
+	}
+
+	keys := make([]string, 0, len(doc))
+	for key := range doc {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	var errs []FieldError
+	for _, key := range keys {
+		if err := setPatchField(&patch, key, doc[key]); err != nil {
+			errs = append(errs, *err)
+		}
+	}
+	return patch, errs, nil
+}
+
+// setPatchField sets field of patch to value; null clears the field.
+func setPatchField(patch *db.PersonPatch, field string, value json.RawMessage) *FieldError {
+	text := func() (*string, *FieldError) {
+		s := ""
+		if !isJSONNull(value) && json.Unmarshal(value, &s) != nil {
+			return nil, &FieldError{Field: field, Message: "must be a string or null"}
+		}
+		return &s, nil
+	}
+
+	var err *FieldError
+	switch field {
+	case "name":
+		patch.Name, err = text()
+	case "surname":
+		patch.Surname, err = text()
+	case "patronymic":
+		patch.Patronymic, err = text()
+	case "gender":
+		patch.Gender, err = text()
+	case "nationality":
+		patch.Nationality, err = text()
+	case "age":
+		age := 0
+		if !isJSONNull(value) && json.Unmarshal(value, &age) != nil {
+			return &FieldError{Field: field, Message: "must be an integer or null"}
+		}
+		patch.Age = &age
+	default:
+		return &FieldError{Field: field, Message: "cannot be patched"}
+	}
+	return err
+}
+
+func isJSONNull(value json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(value), []byte("null"))
+}
+
+const jsonPatchContentType = "application/json-patch+json"
+
+// errPatchTestFailed is returned when a JSON Patch test operation does not
+// hold against the current record.
+var errPatchTestFailed = errors.New("patch test failed")
+
+// PatchOperation is an RFC 6902 operation on one of the fields of
+// PersonMergePatch, e.g. {"op": "test", "path": "/nationality", "value": "RU"}.
+type PatchOperation struct {
+	Op    string          `json:"op" enums:"replace,remove,test" example:"replace"`
+	Path  string          `json:"path" example:"/nationality"`
+	Value json.RawMessage `json:"value,omitempty" swaggertype:"string" example:"KZ"`
+}
+
+// decodeJSONPatch reads an RFC 6902 patch document. Malformed JSON is an
+// error; unsupported operations and paths are reported as validation errors.
+func decodeJSONPatch(body io.Reader) ([]PatchOperation, []FieldError, error) {
+	var ops []PatchOperation
+	if err := json.NewDecoder(body).Decode(&ops); err != nil {
+		return nil, nil, fmt.Errorf("json patch must be an array of operations")
+	}
+
+	// Errors are reported against the operation, e.g. "/1" for the second.
+	var errs []FieldError
+	for i, op := range ops {
+		opError := func(format string, args ...interface{}) {
+			errs = append(errs, FieldError{Field: fmt.Sprintf("/%d", i), Message: fmt.Sprintf(format, args...)})
+		}
+		value := op.Value
+		switch op.Op {
+		case "replace", "test":
+			if value == nil {
+				opError("%s requires a value", op.Op)
+				continue
+			}
+		case "remove":
+			value = json.RawMessage("null")
+		default:
+			opError("op %q is not supported", op.Op)
+			continue
+		}
+		field, ok := strings.CutPrefix(op.Path, "/")
+		if !ok {
+			opError("invalid path %q", op.Path)
+			continue
+		}
+		if err := setPatchField(&db.PersonPatch{}, field, value); err != nil {
+			opError("%s", err)
+		}
+	}
+	return ops, errs, nil
+}
+
+// applyJSONPatch runs ops in order against current and returns the resulting
+// changes as a single patch, so that they are written together or not at
+// all. A failing test makes the whole patch fail with errPatchTestFailed.
+// ops must have been checked by decodeJSONPatch.
+func applyJSONPatch(ops []PatchOperation, current db.Person) (db.PersonPatch, error) {
+	var patch db.PersonPatch
+	for i, op := range ops {
+		field := strings.TrimPrefix(op.Path, "/")
+		switch op.Op {
+		case "replace":
+			setPatchField(&patch, field, op.Value)
+		case "remove":
+			setPatchField(&patch, field, json.RawMessage("null"))
+		case "test":
+			// The test holds if setting the value changes nothing.
+			var expected db.PersonPatch
+			setPatchField(&expected, field, op.Value)
+			if state := patch.Apply(current); expected.Apply(state) != state {
+				return patch, fmt.Errorf("operation %d: %s is not %s: %w", i, op.Path, op.Value, errPatchTestFailed)
+			}
+		}
+	}
+	return patch, nil
+}
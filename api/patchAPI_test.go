@@ -0,0 +1,142 @@
+package api
+
+import (
+	db "db"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func strPtr(s string) *string { return &s }
+func intPtr(n int) *int       { return &n }
+
+func TestDecodeMergePatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		want     db.PersonPatch
+		wantErrs []FieldError
+		wantErr  bool
+	}{
+		{name: "empty", body: `{}`},
+		{name: "set", body: `{"surname": "Петров", "age": 31}`, want: db.PersonPatch{Surname: strPtr("Петров"), Age: intPtr(31)}},
+		{name: "null clears", body: `{"patronymic": null, "age": null}`, want: db.PersonPatch{Patronymic: strPtr(""), Age: intPtr(0)}},
+		{
+			name:     "wrong types and unknown fields",
+			body:     `{"age": "old", "name": 5, "id": 3}`,
+			wantErrs: []FieldError{{"age", "must be an integer or null"}, {"id", "cannot be patched"}, {"name", "must be a string or null"}},
+		},
+		{name: "not an object", body: `[]`, wantErr: true},
+		{name: "null document", body: `null`, wantErr: true},
+		{name: "malformed", body: `{"age":`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, errs, err := decodeMergePatch(strings.NewReader(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(errs, tt.wantErrs) {
+				t.Errorf("field errors = %v, want %v", errs, tt.wantErrs)
+			}
+			if len(tt.wantErrs) == 0 && !reflect.DeepEqual(patch, tt.want) {
+				t.Errorf("patch = %+v, want %+v", patch, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeJSONPatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		wantErrs []FieldError
+		wantErr  bool
+	}{
+		{name: "valid", body: `[{"op": "test", "path": "/age", "value": 30}, {"op": "replace", "path": "/age", "value": 31}, {"op": "remove", "path": "/patronymic"}]`},
+		{
+			name: "invalid operations",
+			body: `[{"op": "add", "path": "/age", "value": 1}, {"op": "replace", "path": "/age"}, {"op": "replace", "path": "age", "value": 1}, {"op": "replace", "path": "/id", "value": 1}]`,
+			wantErrs: []FieldError{
+				{"/0", `op "add" is not supported`},
+				{"/1", "replace requires a value"},
+				{"/2", `invalid path "age"`},
+				{"/3", "id cannot be patched"},
+			},
+		},
+		{name: "not an array", body: `{"op": "remove", "path": "/age"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, errs, err := decodeJSONPatch(strings.NewReader(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(errs, tt.wantErrs) {
+				t.Errorf("field errors = %v, want %v", errs, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	current := db.Person{ID: 1, Name: "Иван", Surname: "Иванов", Patronymic: "Иванович", Age: 30, Gender: "male", Nationality: "RU"}
+
+	tests := []struct {
+		name       string
+		body       string
+		want       db.Person
+		wantFailed bool
+	}{
+		{
+			name: "test then replace",
+			body: `[{"op": "test", "path": "/age", "value": 30}, {"op": "replace", "path": "/age", "value": 31}]`,
+			want: func() db.Person { p := current; p.Age = 31; return p }(),
+		},
+		{
+			name: "test sees earlier operations",
+			body: `[{"op": "replace", "path": "/nationality", "value": "KZ"}, {"op": "test", "path": "/nationality", "value": "KZ"}, {"op": "remove", "path": "/patronymic"}]`,
+			want: func() db.Person { p := current; p.Nationality, p.Patronymic = "KZ", ""; return p }(),
+		},
+		{
+			name:       "failing test discards the whole patch",
+			body:       `[{"op": "replace", "path": "/age", "value": 31}, {"op": "test", "path": "/surname", "value": "Петров"}]`,
+			wantFailed: true,
+		},
+		{
+			name:       "test of a removed field",
+			body:       `[{"op": "remove", "path": "/patronymic"}, {"op": "test", "path": "/patronymic", "value": "Иванович"}]`,
+			wantFailed: true,
+		},
+		{
+			name: "test null against an empty field",
+			body: `[{"op": "remove", "path": "/patronymic"}, {"op": "test", "path": "/patronymic", "value": null}]`,
+			want: func() db.Person { p := current; p.Patronymic = ""; return p }(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops, errs, err := decodeJSONPatch(strings.NewReader(tt.body))
+			if err != nil || len(errs) > 0 {
+				t.Fatalf("decode: %v %v", err, errs)
+			}
+			patch, err := applyJSONPatch(ops, current)
+			if tt.wantFailed {
+				if !errors.Is(err, errPatchTestFailed) {
+					t.Fatalf("err = %v, want errPatchTestFailed", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := patch.Apply(current); got != tt.want {
+				t.Errorf("result = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	db "db"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const problemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details body. Code is stable and meant for
+// clients to branch on; Detail is for humans and may change.
+type Problem struct {
+	Type       string       `json:"type" example:"about:blank"`
+	Title      string       `json:"title" example:"Not Found"`
+	Status     int          `json:"status" example:"404"`
+	Code       string       `json:"code" example:"not_found"`
+	Detail     string       `json:"detail,omitempty" example:"person with id 42 not found"`
+	Instance   string       `json:"instance,omitempty" example:"/people/42"`
+	RequestID  string       `json:"request_id,omitempty"`
+	Errors     []FieldError `json:"errors,omitempty"`
+	Candidates []int        `json:"candidates,omitempty"`
+}
+
+// Stable problem codes.
+const (
+	codeBadRequest           = "bad_request"
+	codeValidation           = "validation_failed"
+	codeNotFound             = "not_found"
+	codeConflict             = "conflict"
+	codeDuplicate            = "duplicate"
+	codeVersionMismatch      = "version_mismatch"
+	codePatchTestFailed      = "patch_test_failed"
+	codeForbidden            = "forbidden"
+	codeTooLarge             = "too_large"
+	codeUnsupportedMedia     = "unsupported_media_type"
+	codeNotAcceptable        = "not_acceptable"
+	codePreconditionRequired = "precondition_required"
+	codeUpstream             = "upstream_failure"
+	codeInternal             = "internal"
+)
+
+// APIError is the error handlers return instead of writing an error
+// response themselves; makeHTTPHandleFunc renders it as a Problem. Build it
+// with one of the constructors below, which make up the error kinds the API
+// knows: invalid requests, validation failures, missing resources,
+// conflicts, upstream failures and internal errors.
+type APIError struct {
+	Status     int
+	Code       string
+	Detail     string
+	Errors     []FieldError
+	Candidates []int
+	// Err is the cause. It is logged, never sent to the client.
+	Err error
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%d %s: %s", e.Status, e.Code, e.Detail)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// requestError rejects a request the API cannot process as sent: malformed
+// input, wrong media type, missing permissions or preconditions.
+func requestError(status int, code, detail string) *APIError {
+	return &APIError{Status: status, Code: code, Detail: detail}
+}
+
+func badRequest(format string, args ...interface{}) *APIError {
+	return requestError(http.StatusBadRequest, codeBadRequest, fmt.Sprintf(format, args...))
+}
+
+func validationError(detail string, errs []FieldError) *APIError {
+	return &APIError{Status: http.StatusUnprocessableEntity, Code: codeValidation, Detail: detail, Errors: errs}
+}
+
+func notFound(format string, args ...interface{}) *APIError {
+	return &APIError{Status: http.StatusNotFound, Code: codeNotFound, Detail: fmt.Sprintf(format, args...)}
+}
+
+func personNotFound(id int) *APIError {
+	return notFound("person with id %d not found", id)
+}
+
+func conflict(code, detail string) *APIError {
+	return &APIError{Status: http.StatusConflict, Code: code, Detail: detail}
+}
+
+func upstreamError(detail string, err error) *APIError {
+	return &APIError{Status: http.StatusBadGateway, Code: codeUpstream, Detail: detail, Err: err}
+}
+
+func internalError(err error) *APIError {
+	return &APIError{Status: http.StatusInternalServerError, Code: codeInternal, Detail: "internal server error", Err: err}
+}
+
+// asAPIError maps any error a handler returns to an APIError. Storage
+// errors that mean the same thing everywhere are mapped here; anything
+// unknown is internal.
+func asAPIError(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	switch {
+	case errors.Is(err, db.ErrPersonNotFound), errors.Is(err, db.ErrPersonMerged):
+		return &APIError{Status: http.StatusNotFound, Code: codeNotFound, Detail: "person not found", Err: err}
+	case errors.Is(err, db.ErrVersionMismatch):
+		return &APIError{Status: http.StatusConflict, Code: codeVersionMismatch, Detail: "person was modified concurrently, retry the request", Err: err}
+	case errors.Is(err, db.ErrInvalidQuery):
+		return &APIError{Status: http.StatusBadRequest, Code: codeBadRequest, Detail: err.Error(), Err: err}
+	}
+	return internalError(err)
+}
+
+// writeProblem renders err as problem details.
+func writeProblem(w http.ResponseWriter, r *http.Request, err error) error {
+	apiErr := asAPIError(err)
+	problem := Problem{
+		Type:       "about:blank",
+		Title:      http.StatusText(apiErr.Status),
+		Status:     apiErr.Status,
+		Code:       apiErr.Code,
+		Detail:     apiErr.Detail,
+		Instance:   r.URL.Path,
+		RequestID:  r.Header.Get("X-Request-ID"),
+		Errors:     apiErr.Errors,
+		Candidates: apiErr.Candidates,
+	}
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(apiErr.Status)
+	return json.NewEncoder(w).Encode(problem)
+}
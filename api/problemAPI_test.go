@@ -0,0 +1,112 @@
+package api
+
+import (
+	db "db"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestAsAPIError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{"not found", fmt.Errorf("person with id 1: %w", db.ErrPersonNotFound), http.StatusNotFound, codeNotFound},
+		{"merged", &db.MergedError{ID: 1, SurvivorID: 2}, http.StatusNotFound, codeNotFound},
+		{"version mismatch", fmt.Errorf("update: %w", db.ErrVersionMismatch), http.StatusConflict, codeVersionMismatch},
+		{"invalid query", fmt.Errorf("%w: cannot sort by %q", db.ErrInvalidQuery, "x"), http.StatusBadRequest, codeBadRequest},
+		{"unknown", errors.New("connection refused"), http.StatusInternalServerError, codeInternal},
+		{"bad request", badRequest("invalid id"), http.StatusBadRequest, codeBadRequest},
+		{"validation", validationError("invalid person", nil), http.StatusUnprocessableEntity, codeValidation},
+		{"person not found", personNotFound(7), http.StatusNotFound, codeNotFound},
+		{"conflict", conflict(codeDuplicate, "duplicate"), http.StatusConflict, codeDuplicate},
+		{"upstream", upstreamError("failed to enrich person", errors.New("timeout")), http.StatusBadGateway, codeUpstream},
+		{"precondition", requestError(http.StatusPreconditionRequired, codePreconditionRequired, "If-Match header is required"), http.StatusPreconditionRequired, codePreconditionRequired},
+		{"wrapped api error", fmt.Errorf("handler: %w", personNotFound(7)), http.StatusNotFound, codeNotFound},
+	}
+	for _, tt := range tests {
+		got := asAPIError(tt.err)
+		if got.Status != tt.wantStatus || got.Code != tt.wantCode {
+			t.Errorf("%s: asAPIError = %d %s, want %d %s", tt.name, got.Status, got.Code, tt.wantStatus, tt.wantCode)
+		}
+	}
+}
+
+func TestInternalErrorHidesCause(t *testing.T) {
+	err := asAPIError(errors.New("pq: password authentication failed"))
+	if err.Detail != "internal server error" {
+		t.Errorf("detail = %q, leaks the cause", err.Detail)
+	}
+	if !errors.Is(err, err.Err) {
+		t.Error("cause is not kept for logging")
+	}
+}
+
+func TestMakeHTTPHandleFuncWritesProblem(t *testing.T) {
+	discardLogs(t)
+
+	errs := []FieldError{{Field: "age", Message: "is required"}}
+	handler := makeHTTPHandleFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return validationError("invalid person", errs)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/people", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != problemContentType {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var problem Problem
+	if err := json.NewDecoder(rec.Body).Decode(&problem); err != nil {
+		t.Fatal(err)
+	}
+	want := Problem{
+		Type:      "about:blank",
+		Title:     "Unprocessable Entity",
+		Status:    http.StatusUnprocessableEntity,
+		Code:      codeValidation,
+		Detail:    "invalid person",
+		Instance:  "/people",
+		RequestID: "req-1",
+		Errors:    errs,
+	}
+	if !reflect.DeepEqual(problem, want) {
+		t.Errorf("problem = %+v, want %+v", problem, want)
+	}
+}
+
+func TestMakeHTTPHandleFuncKeepsStartedResponse(t *testing.T) {
+	discardLogs(t)
+
+	handler := makeHTTPHandleFunc(func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("partial"))
+		return errors.New("stream broke")
+	})
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/people/export", nil))
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "partial" {
+		t.Errorf("response = %d %q, want the started response untouched", rec.Code, rec.Body)
+	}
+}
+
+func discardLogs(t *testing.T) {
+	saved := log.Writer()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(saved) })
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	db "db"
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// @Summary Сводная таблица (crosstab)
+// @Description Матрица rows × cols с мерой count или avg_age по людям, подходящим под фильтры GET /people. Количество строк и столбцов ограничено. При format=csv отдается CSV
+// @Tags reports
+// @Accept  json
+// @Produce  json
+// @Produce  text/csv
+// @Param rows query string true "Измерение строк" Enums(nationality, gender, age_band)
+// @Param cols query string true "Измерение столбцов" Enums(nationality, gender, age_band)
+// @Param measure query string false "Мера" Enums(count, avg_age) default(count)
+// @Param band query int false "Ширина возрастной группы для age_band (по умолчанию: 10)" default(10)
+// @Param format query string false "Формат ответа" Enums(json, csv) default(json)
+// @Param filter[field][op] query string false "Типизированный фильтр, как в GET /people"
+// @Param include_deleted query bool false "Показать также удаленные записи (только для администраторов с заголовком X-Admin-Token)"
+// @Success 200 {object} db.Crosstab
+// @Failure 400 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /reports/crosstab [get]
+func (s *APIServer) handleGetCrosstab(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+
+	opts := db.CrosstabOptions{
+		Rows:    query.Get("rows"),
+		Cols:    query.Get("cols"),
+		Measure: db.CrosstabMeasure(query.Get("measure")),
+		AgeBand: parseIntPagination(query.Get("band"), 10),
+	}
+	if opts.Measure == "" {
+		opts.Measure = db.MeasureCount
+	}
+
+	format := query.Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		return badRequest("format must be json or csv")
+	}
+
+	filter, err := parsePeopleFilter(query)
+	if err != nil {
+		return badRequest("%s", err)
+	}
+	if filter.IncludeDeleted, err = s.includeDeleted(r); err != nil {
+		return err
+	}
+
+	report, err := s.dbStorage.GetCrosstab(filter, opts)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidQuery) {
+			return badRequest("%s", err)
+		}
+		return internalError(err)
+	}
+
+	if format != "csv" {
+		return WriteJson(w, http.StatusOK, report)
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="crosstab.csv"`)
+	w.WriteHeader(http.StatusOK)
+	return writeCrosstabCSV(csv.NewWriter(w), report)
+}
+
+func writeCrosstabCSV(out *csv.Writer, report db.Crosstab) error {
+	cell := func(v *float64) string {
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*v, 'f', -1, 64)
+	}
+
+	header := append([]string{report.Rows + `\` + report.Cols}, report.ColKeys...)
+	if err := out.Write(append(header, "total")); err != nil {
+		return err
+	}
+	for i, key := range report.RowKeys {
+		record := []string{key}
+		for _, v := range report.Values[i] {
+			record = append(record, cell(v))
+		}
+		if err := out.Write(append(record, cell(report.RowTotals[i]))); err != nil {
+			return err
+		}
+	}
+	totals := []string{"total"}
+	for _, v := range report.ColTotals {
+		totals = append(totals, cell(v))
+	}
+	if err := out.Write(append(totals, cell(report.GrandTotal))); err != nil {
+		return err
+	}
+
+	out.Flush()
+	return out.Error()
+}
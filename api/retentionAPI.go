@@ -0,0 +1,57 @@
+package api
+
+import (
+	db "db"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+const defaultPurgeInterval = time.Hour
+
+// purgeInterval reads PURGE_INTERVAL, how often the server purges people
+// deleted longer than DELETED_RETENTION ago. 0 turns the periodic purge off,
+// e.g. when it runs from cron with -purge-deleted instead.
+func purgeInterval() (time.Duration, error) {
+	env := os.Getenv("PURGE_INTERVAL")
+	if env == "" {
+		return defaultPurgeInterval, nil
+	}
+	interval, err := time.ParseDuration(env)
+	if err != nil || interval < 0 {
+		return 0, fmt.Errorf("invalid PURGE_INTERVAL %q: expected a duration like 1h", env)
+	}
+	return interval, nil
+}
+
+// startPurge reads the retention settings and, unless the periodic purge is
+// turned off, purges in the background: once at startup, then every
+// interval. A failed purge is logged and retried on the next tick.
+func (s *APIServer) startPurge() error {
+	retention, err := db.DeletedRetention()
+	if err != nil {
+		return err
+	}
+	interval, err := purgeInterval()
+	if err != nil {
+		return err
+	}
+	if interval == 0 {
+		return nil
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if n, err := s.dbStorage.PurgeDeleted(retention); err != nil {
+				log.Printf("failed to purge deleted people: %v", err)
+			} else if n > 0 {
+				log.Printf("purged %d deleted people", n)
+			}
+			<-ticker.C
+		}
+	}()
+	return nil
+}
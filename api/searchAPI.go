@@ -0,0 +1,72 @@
+package api
+
+import (
+	db "db"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+type SearchResults struct {
+	Query          string               `json:"query"`
+	Page           int                  `json:"page"`
+	PagesTotal     int                  `json:"pages_total"`
+	EntriesTotal   int                  `json:"entries_total"`
+	EntriesPerPage int                  `json:"entries_per_page"`
+	Results        []db.PersonSearchHit `json:"results"`
+}
+
+// @Summary Полнотекстовый поиск людей
+// @Description Поиск по имени, фамилии и отчеству одной строкой (русская морфология и префиксы слов). Результаты ранжированы, совпадения выделены тегом <mark> в поле highlight
+// @Tags people
+// @Accept  json
+// @Produce  json
+// @Param q query string true "Поисковая строка"
+// @Param page query int false "Номер страницы (по умолчанию: 1)" default(1)
+// @Param entries query int false "Количество записей на странице (по умолчанию: 10)" default(10)
+// @Param filter[field][op] query string false "Типизированный фильтр, как в GET /people"
+// @Param include_deleted query bool false "Показать также удаленные записи (только для администраторов с заголовком X-Admin-Token)"
+// @Success 200 {object} SearchResults
+// @Failure 400 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /people/search [get]
+func (s *APIServer) handleSearchPeople(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		return badRequest("q is required")
+	}
+
+	page := parseIntPagination(query.Get("page"), 1)
+	entries := parseIntPagination(query.Get("entries"), 10)
+
+	filter, err := parsePeopleFilter(query)
+	if err != nil {
+		return badRequest("%s", err)
+	}
+	if filter.IncludeDeleted, err = s.includeDeleted(r); err != nil {
+		return err
+	}
+
+	hits, total, err := s.dbStorage.SearchPeopleFullText(q, filter, entries, (page-1)*entries)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidQuery) {
+			return badRequest("%s", err)
+		}
+		return internalError(err)
+	}
+	if hits == nil {
+		hits = []db.PersonSearchHit{}
+	}
+
+	return WriteJson(w, http.StatusOK, SearchResults{
+		Query:          q,
+		Page:           page,
+		PagesTotal:     (total + entries - 1) / entries,
+		EntriesTotal:   total,
+		EntriesPerPage: entries,
+		Results:        hits,
+	})
+}
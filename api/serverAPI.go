@@ -3,26 +3,42 @@ package api
 import (
 	"db"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
-var ExtAPIs []string = []string{
-	"https://api.nationalize.io/",
-	"https://api.genderize.io/",
-	"https://api.agify.io/",
-}
+// Enrichment providers; ProcessExtAPIs tells their responses apart by URL.
+var (
+	nationalizeAPI = "https://api.nationalize.io/"
+	genderizeAPI   = "https://api.genderize.io/"
+	agifyAPI       = "https://api.agify.io/"
+)
+
+var ExtAPIs []string = []string{nationalizeAPI, genderizeAPI, agifyAPI}
 
 func (s *APIServer) RunAPIServer() {
+	if _, _, err := s.countries(); err != nil {
+		log.Fatalf("failed to load countries: %v", err)
+	}
+	if err := s.startPurge(); err != nil {
+		log.Fatalf("failed to start the purge of deleted people: %v", err)
+	}
 	router := NewRouter()
 	router.HandleEndpoints(s)
 	log.Printf("server started on %s \n", s.listenAddr)
 	if err := http.ListenAndServe(s.listenAddr, router.mux); err != nil {
-		fmt.Errorf("failed to start a server on %s", s.listenAddr)
+		log.Fatalf("failed to start a server on %s: %v", s.listenAddr, err)
 	}
 }
 
@@ -32,25 +48,54 @@ func (r Router) HandleEndpoints(s *APIServer) {
 
 	m.HandleFunc("GET /people", makeHTTPHandleFunc(s.handleGetPeopleWithPagination))
 
+	m.HandleFunc("GET /people/{id}", makeHTTPHandleFunc(s.handleGetPerson))
+
+	m.HandleFunc("GET /people/{id}/duplicates", makeHTTPHandleFunc(s.handleGetPersonDuplicates))
+
+	m.HandleFunc("GET /people/{id}/history", makeHTTPHandleFunc(s.handleGetPersonHistory))
+
 	m.HandleFunc("POST /people", makeHTTPHandleFunc(s.handleCreatePeople))
 
+	m.HandleFunc("POST /people/import", makeHTTPHandleFunc(s.handleImportPeople))
+
+	m.HandleFunc("POST /people/merge", makeHTTPHandleFunc(s.handleMergePeople))
+
+	m.HandleFunc("GET /people/export", makeHTTPHandleFunc(s.handleExportPeople))
+
+	m.HandleFunc("GET /people/search", makeHTTPHandleFunc(s.handleSearchPeople))
+
+	m.HandleFunc("GET /people/stats", makeHTTPHandleFunc(s.handleGetPeopleStats))
+
+	m.HandleFunc("GET /reports/crosstab", makeHTTPHandleFunc(s.handleGetCrosstab))
+
+	m.HandleFunc("GET /countries", makeHTTPHandleFunc(s.handleGetCountries))
+
 	m.HandleFunc("PATCH /people/{id}", makeHTTPHandleFunc(s.handleUpdatePeopleSkipEnrich))
 
+	m.HandleFunc("POST /people/bulk", makeHTTPHandleFunc(s.handleBulkPeople))
+
+	m.HandleFunc("PATCH /people", makeHTTPHandleFunc(s.handlePatchPeopleByFilter))
+
+	m.HandleFunc("DELETE /people", makeHTTPHandleFunc(s.handleDeletePeopleByFilter))
+
 	m.HandleFunc("PUT /people/enrich/{id}", makeHTTPHandleFunc(s.handleUpdatePeopleEnrich))
 
 	m.HandleFunc("DELETE /people/{id}", makeHTTPHandleFunc(s.handleDeletePeople))
+
+	m.HandleFunc("POST /people/{id}/restore", makeHTTPHandleFunc(s.handleRestorePerson))
 }
 
 type PaginatedFilteredResults struct {
-	Page           int         `json:"page"`
-	PagesTotal     int         `json:"pages_total"`
-	EntriesTotal   int         `json:"entries_total"`
-	EntriesPerPage int         `json:"entries_per_page"`
-	People         []db.Person `json:"people"`
+	Page           int          `json:"page"`
+	PagesTotal     int          `json:"pages_total"`
+	EntriesTotal   int          `json:"entries_total"`
+	EntriesPerPage int          `json:"entries_per_page"`
+	People         []PersonView `json:"people"`
 }
 
 // @Summary Список людей с фильтрацией и пагинацией
-// @Description Получить пагинированный список людей с возможностью фильтрации по различным параметрам
+// @Description Получить пагинированный список людей с возможностью фильтрации по различным параметрам.
+// @Description Поддерживаются постраничный режим (page/entries) и курсорный режим (after/before/limit) со ссылками next/prev
 // @Tags people
 // @Accept  json
 // @Produce  json
@@ -58,42 +103,80 @@ type PaginatedFilteredResults struct {
 // @Param surname query string false "Фильтрация по фамилии (частичное совпадение)"
 // @Param patronymic query string false "Фильтрация по отчество"
 // @Param age query int false "Фильтрация по возрасту"
-// @Param nationality query string false "Фильтрация по национальности"
-// @Param gender query string false "Фильтрация по полу"
+// @Param nationality query string false "Фильтрация по национальности (точное совпадение)"
+// @Param gender query string false "Фильтрация по полу (точное совпадение)"
+// @Param filter[field][op] query string false "Типизированный фильтр, например filter[age][gte]=18, filter[nationality][in]=RU,UA. Операторы: eq, neq, in, prefix, contains, gt, gte, lt, lte, is_null. is_null — только для patronymic, nationality (пустое значение) и enriched_at; имена сравниваются без учета регистра, пробелов и Ё/Е"
+// @Param q query string false "Нечеткий поиск по ФИО (триграммы и фонетический ключ); результаты ранжируются по score"
+// @Param sort query string false "Сортировка: поля через запятую, '-' перед полем — по убыванию (id, name, surname, patronymic, age, gender, nationality, updated_at)" example(surname,-age,id)
 // @Param page query int false "Номер страницы (по умолчанию: 1)" default(1)
 // @Param entries query int false "Количество записей на странице (по умолчанию: 10)" default(10)
-// @Success 200 {object} PaginatedFilteredResults
-// @Failure 400 {object} ApiError
-// @Failure 500 {object} ApiError
+// @Param after query string false "Курсор: записи после данной позиции (включает курсорную пагинацию)"
+// @Param before query string false "Курсор: записи до данной позиции (включает курсорную пагинацию)"
+// @Param limit query int false "Размер страницы в курсорном режиме (по умолчанию: 10)" default(10)
+// @Param count query string false "Подсчет общего количества в курсорном режиме" Enums(none, exact, estimate)
+// @Param as_of query string false "Показать записи такими, какими они были в указанный момент (RFC 3339), по истории изменений; несовместим с q" example(2024-05-01T00:00:00Z)
+// @Param expand query string false "Развернуть поля: nationality — объект страны вместо кода" Enums(nationality)
+// @Param include_deleted query bool false "Показать также удаленные записи (только для администраторов с заголовком X-Admin-Token)"
+// @Success 200 {object} PaginatedFilteredResults "В курсорном режиме (after/before/limit) возвращается CursorPaginatedResults"
+// @Failure 400 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 500 {object} Problem
 // @Router /people [get]
 func (s *APIServer) handleGetPeopleWithPagination(w http.ResponseWriter, r *http.Request) error {
 	query := r.URL.Query()
 
-	fname := query.Get("fname")
-	surname := query.Get("surname")
-	patronymic := query.Get("patronymic")
-	ageStr := query.Get("age")
-	nationality := query.Get("nationality")
-	gender := query.Get("gender")
-
 	page := parseIntPagination(query.Get("page"), 1)
 	entries := parseIntPagination(query.Get("entries"), 10)
 	offset := (page - 1) * entries
 
-	var age int
-	if ageStr != "" {
-		parsedAge, err := strconv.Atoi(ageStr)
-		if err != nil || parsedAge < 1 {
-			WriteJson(w, http.StatusBadRequest, "invalid age")
-			return err
+	filter, err := parsePeopleFilter(query)
+	if err != nil {
+		return badRequest("%s", err)
+	}
+	if filter.IncludeDeleted, err = s.includeDeleted(r); err != nil {
+		return err
+	}
+	if filter.AsOf, err = parseAsOf(query.Get("as_of")); err != nil {
+		return badRequest("%s", err)
+	}
+	expandNationality, err := parseExpand(r)
+	if err != nil {
+		return err
+	}
+
+	sort, err := db.ParseSort(query.Get("sort"))
+	if err != nil {
+		return badRequest("%s", err)
+	}
+
+	if filter.AsOf != nil && query.Get("q") != "" {
+		return badRequest("q is not supported with as_of")
+	}
+
+	if isCursorPagination(query) {
+		if query.Get("q") != "" {
+			return badRequest("q is not supported with cursor pagination")
+		}
+		return s.handleGetPeopleKeyset(w, r, filter, sort, expandNationality)
+	}
+
+	var people []db.Person
+	var total int
+	if q := strings.TrimSpace(query.Get("q")); q != "" {
+		if !query.Has("sort") {
+			sort = nil
 		}
-		age = parsedAge
+		people, total, err = s.dbStorage.SearchPeople(q, filter, sort, entries, offset)
+	} else {
+		people, total, err = s.dbStorage.GetPeopleWithPagination(filter, sort, entries, offset)
+	}
+	if err != nil {
+		return internalError(err)
 	}
 
-	people, total, err := s.dbStorage.GetPeopleWithPagination(fname, surname, patronymic, age, nationality, gender, entries, offset)
+	views, err := s.personViews(people, expandNationality)
 	if err != nil {
-		WriteJson(w, http.StatusInternalServerError, "internal server error")
-		return err
+		return internalError(err)
 	}
 
 	pagesTotal := (total + entries - 1) / entries
@@ -102,13 +185,88 @@ func (s *APIServer) handleGetPeopleWithPagination(w http.ResponseWriter, r *http
 		PagesTotal:     pagesTotal,
 		EntriesTotal:   total,
 		EntriesPerPage: entries,
-		People:         people,
+		People:         views,
 	}
 
 	WriteJson(w, http.StatusOK, response)
 	return nil
 }
 
+var filterParamRe = regexp.MustCompile(`^filter\[(\w+)\](?:\[(\w+)\])?$`)
+
+// legacyFilterParams keeps the original flat query parameters working.
+// Names match by substring as before; the rest are exact.
+var legacyFilterParams = []struct {
+	param, field string
+	op           db.FilterOp
+}{
+	{"fname", "name", db.OpContains},
+	{"surname", "surname", db.OpContains},
+	{"patronymic", "patronymic", db.OpContains},
+	{"age", "age", db.OpEq},
+	{"nationality", "nationality", db.OpEq},
+	{"gender", "gender", db.OpEq},
+}
+
+// parsePeopleFilter reads filter[field][op]=value parameters (op defaults to
+// eq) together with the legacy flat parameters.
+func parsePeopleFilter(query url.Values) (db.PeopleFilter, error) {
+	var filter db.PeopleFilter
+
+	for _, legacy := range legacyFilterParams {
+		value := query.Get(legacy.param)
+		if value == "" {
+			continue
+		}
+		cond, err := db.ParseCondition(legacy.field, legacy.op, value)
+		if err != nil {
+			return filter, err
+		}
+		filter.Add(cond)
+	}
+
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		m := filterParamRe.FindStringSubmatch(key)
+		if m == nil {
+			if strings.HasPrefix(key, "filter") {
+				return filter, fmt.Errorf("%w: malformed filter parameter %q", db.ErrInvalidQuery, key)
+			}
+			continue
+		}
+		op := db.FilterOp(m[2])
+		if op == "" {
+			op = db.OpEq
+		}
+		for _, value := range query[key] {
+			cond, err := db.ParseCondition(m[1], op, value)
+			if err != nil {
+				return filter, err
+			}
+			filter.Add(cond)
+		}
+	}
+
+	return filter, nil
+}
+
+// parseAsOf reads an as_of timestamp. An empty value means the current state.
+func parseAsOf(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, errors.New("as_of must be an RFC 3339 timestamp, e.g. 2024-05-01T00:00:00Z")
+	}
+	return &t, nil
+}
+
 func parseIntPagination(s string, count int) int {
 	countInt, err := strconv.Atoi(s)
 	if err != nil || countInt < 1 {
@@ -117,178 +275,383 @@ func parseIntPagination(s string, count int) int {
 	return countInt
 }
 
+// @Summary Получение человека по ID
+// @Description Получить полную запись о человеке вместе с метаданными обогащения. Поддерживает условные запросы через If-None-Match.
+// @Description Для записи, объединенной с другой, возвращается 301 с Location и Link rel="canonical" на основную запись
+// @Tags people
+// @Accept  json
+// @Produce  json
+// @Param id path int true "ID человека"
+// @Param If-None-Match header string false "ETag ранее полученной версии записи"
+// @Param as_of query string false "Вернуть запись такой, какой она была в указанный момент (RFC 3339), по истории изменений" example(2024-05-01T00:00:00Z)
+// @Param expand query string false "Развернуть поля: nationality — объект страны вместо кода" Enums(nationality)
+// @Success 200 {object} PersonView
+// @Success 304 "Запись не изменилась"
+// @Success 301 "Запись объединена с другой"
+// @Header 301 {string} Location "/people/{id} основной записи"
+// @Failure 400 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /people/{id} [get]
+func (s *APIServer) handleGetPerson(w http.ResponseWriter, r *http.Request) error {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return badRequest("invalid id")
+	}
+
+	asOf, err := parseAsOf(r.URL.Query().Get("as_of"))
+	if err != nil {
+		return badRequest("%s", err)
+	}
+	expandNationality, err := parseExpand(r)
+	if err != nil {
+		return err
+	}
+
+	var person db.Person
+	if asOf != nil {
+		person, err = s.dbStorage.GetPersonAsOf(id, *asOf)
+	} else {
+		person, err = s.dbStorage.GetPerson(id)
+	}
+	if err != nil {
+		if errors.Is(err, db.ErrPersonNotFound) {
+			return personNotFound(id)
+		}
+		var merged *db.MergedError
+		if errors.As(err, &merged) {
+			return writeMergedRedirect(w, r, merged, "")
+		}
+		return internalError(err)
+	}
+
+	etag := personETag(person)
+	if expandNationality {
+		etag = expandedETag(etag)
+	}
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	views, err := s.personViews([]db.Person{person}, expandNationality)
+	if err != nil {
+		return internalError(err)
+	}
+	return WriteJson(w, http.StatusOK, views[0])
+}
+
 // @Summary Создание нового человека с обогащением данных
-// @Description Создание новой записи о человеке с автоматическим обогащением данных из внешних API
+// @Description Создание новой записи о человеке с автоматическим обогащением данных из внешних API.
+// @Description По умолчанию запись создается всегда. С параметром on_duplicate=reject или return перед созданием ищутся вероятные дубликаты
 // @Tags people
 // @Accept  json
 // @Produce  json
 // @Param person body PersonReq true "Данные о человеке"
-// @Success 200 {object} SuccessResponse
-// @Failure 400 {object} ApiError
-// @Failure 500 {object} ApiError
+// @Param on_duplicate query string false "reject — 409 со списком кандидатов, return — вернуть существующую запись, create — создать без проверки" Enums(reject, return, create) default(create)
+// @Success 201 {object} db.Person
+// @Success 200 {object} db.Person "Найден дубликат при on_duplicate=return"
+// @Header 201 {string} Location "/people/{id} созданной записи"
+// @Header 201 {string} ETag "ETag созданной записи"
+// @Failure 400 {object} Problem
+// @Failure 409 {object} Problem
+// @Failure 422 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure 502 {object} Problem "Внешние API недоступны или не вернули возраст, пол или национальность"
 // @Router /people [post]
 func (s *APIServer) handleCreatePeople(w http.ResponseWriter, r *http.Request) error {
 	person := new(PersonReq)
 	if err := json.NewDecoder(r.Body).Decode(person); err != nil {
-		WriteJson(w, http.StatusBadRequest, "bad request")
-		return nil
+		return badRequest("invalid request body")
+	}
+	if errs := validatePersonReq(*person); len(errs) > 0 {
+		return validationError("invalid person", errs)
 	}
 
-	results := FetchAPIS(person.Name)
+	onDuplicate := r.URL.Query().Get("on_duplicate")
+	switch onDuplicate {
+	case "":
+		onDuplicate = onDuplicateCreate
+	case onDuplicateReject, onDuplicateReturn, onDuplicateCreate:
+	default:
+		return badRequest("on_duplicate must be reject, return or create")
+	}
 
-	process, err := ProcessExtAPIs(results)
-	if err != nil {
-		fmt.Println("err apis", err)
+	if onDuplicate != onDuplicateCreate {
+		candidates, err := s.dbStorage.FindDuplicates(person.Name, person.Surname, person.Patronymic, 0)
+		if err != nil {
+			return internalError(err)
+		}
+		if len(candidates) > 0 {
+			if onDuplicate == onDuplicateReturn {
+				existing := candidates[0].Person
+				w.Header().Set("Location", fmt.Sprintf("/people/%d", existing.ID))
+				w.Header().Set("ETag", personETag(existing))
+				return WriteJson(w, http.StatusOK, existing)
+			}
+			return duplicateError(candidates)
+		}
 	}
-	var enrichedPerson PersonEnriched = PersonEnriched{
-		PersonReq:   *person,
-		Age:         process["age"].(int),
-		Nationality: process["country"].(string),
-		Gender:      process["gender"].(string),
+
+	enrichedPerson, err := enrichPerson(*person)
+	if err != nil {
+		return upstreamError("failed to enrich person", err)
 	}
 
-	if err := s.dbStorage.CreatePerson(enrichedPerson.PersonReq.Name, enrichedPerson.PersonReq.Surname, enrichedPerson.PersonReq.Patronymic, enrichedPerson.Age, enrichedPerson.Gender, enrichedPerson.Nationality); err != nil {
-		log.Printf("err: %s", err)
-		WriteJson(w, http.StatusInternalServerError, "internal server error")
-		return nil
+	created, err := s.dbStorage.CreatePerson(enrichedPerson, changeFor(r, db.SourceCreate))
+	if err != nil {
+		return internalError(err)
 	}
 
-	return WriteJson(w, http.StatusOK, "ok")
+	w.Header().Set("Location", fmt.Sprintf("/people/%d", created.ID))
+	w.Header().Set("ETag", personETag(created))
+	return WriteJson(w, http.StatusCreated, created)
 }
 
 // @Summary Обновление данных человека без обогащения
-// @Description Частичное обновление записи о человеке (без обогащения данных)
+// @Description Частичное обновление записи о человеке (без обогащения данных) в формате JSON Merge Patch (RFC 7396): изменяются только переданные поля, null очищает поле.
+// @Description Также принимается JSON Patch (RFC 6902, application/json-patch+json) с операциями replace, remove и test, например [{"op": "test", "path": "/nationality", "value": "RU"}, {"op": "replace", "path": "/nationality", "value": "KZ"}]. Операции применяются атомарно; если test не выполняется, возвращается 409 и запись не изменяется.
+// @Description Получившаяся запись проверяется целиком; при ошибках возвращается 422
 // @Tags people
+// @Accept  application/merge-patch+json
+// @Accept  application/json-patch+json
 // @Accept  json
 // @Produce  json
 // @Param id path int true "ID человека"
-// @Param person body PersonEnriched true "Частичные данные человека"
-// @Success 200 {object} SuccessResponse
-// @Failure 400 {object} ApiError
-// @Failure 404 {object} ApiError
-// @Failure 500 {object} ApiError
+// @Param person body PersonMergePatch true "Изменяемые поля (для application/json-patch+json — массив PatchOperation)"
+// @Param If-Match header string false "ETag версии, на которой основано изменение; при несовпадении — 412"
+// @Success 200 {object} db.Person
+// @Failure 400 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 409 {object} Problem "Не выполнена операция test или запись изменилась во время обновления"
+// @Failure 412 {object} db.Person "Запись изменилась: текущее представление"
+// @Failure 415 {object} Problem
+// @Failure 422 {object} Problem
+// @Failure 428 {object} Problem "If-Match обязателен (REQUIRE_IF_MATCH=true)"
+// @Failure 500 {object} Problem
 // @Router /people/{id} [patch]
 func (s *APIServer) handleUpdatePeopleSkipEnrich(w http.ResponseWriter, r *http.Request) error {
-	idStr := r.PathValue("id")
-
-	id, err := strconv.Atoi(idStr)
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return badRequest("invalid id")
+	}
 
+	version, err := s.ifMatchVersion(r)
 	if err != nil {
-		log.Printf("err at fmt: %s", err)
-		WriteJson(w, http.StatusBadRequest, "bad request")
-		return nil
+		return err
 	}
 
-	enrichedPerson := new(PersonEnriched)
-	if err := json.NewDecoder(r.Body).Decode(enrichedPerson); err != nil {
-		WriteJson(w, http.StatusBadRequest, "bad request")
-		return nil
+	var (
+		patch db.PersonPatch
+		ops   []PatchOperation
+		errs  []FieldError
+	)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case mergePatchContentType, "application/json":
+		patch, errs, err = decodeMergePatch(r.Body)
+	case jsonPatchContentType:
+		ops, errs, err = decodeJSONPatch(r.Body)
+	default:
+		return requestError(http.StatusUnsupportedMediaType, codeUnsupportedMedia, fmt.Sprintf("expected %s or %s", mergePatchContentType, jsonPatchContentType))
+	}
+	if err != nil {
+		return badRequest("%s", err)
+	}
+	if len(errs) > 0 {
+		return validationError("invalid patch", errs)
 	}
 
-	if err := s.dbStorage.UpdatePersonPatch(id, enrichedPerson.PersonReq.Name, enrichedPerson.PersonReq.Surname, enrichedPerson.PersonReq.Patronymic, enrichedPerson.Age, enrichedPerson.Gender, enrichedPerson.Nationality); err != nil {
-		log.Printf("err at update: %s", err)
+	current, err := s.dbStorage.GetPerson(id)
+	if err != nil {
+		if errors.Is(err, db.ErrPersonNotFound) || errors.Is(err, db.ErrPersonMerged) {
+			return personNotFound(id)
+		}
+		return internalError(err)
+	}
+	if version != db.AnyVersion && current.Version != version {
+		return s.writePreconditionFailed(w, id)
+	}
+	if ops != nil {
+		if patch, err = applyJSONPatch(ops, current); err != nil {
+			return conflict(codePatchTestFailed, err.Error())
+		}
+	}
+	if errs := validatePerson(patch.Apply(current)); len(errs) > 0 {
+		return validationError("invalid person", errs)
+	}
 
-		WriteJson(w, http.StatusNotFound, "internal server error")
-		return nil
+	// The patch was validated, and its tests run, against this version, so
+	// only this version may be overwritten.
+	updated, err := s.dbStorage.UpdatePersonPatch(id, patch, current.Version, changeFor(r, db.SourcePatch))
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrPersonNotFound):
+			return personNotFound(id)
+		case errors.Is(err, db.ErrVersionMismatch) && version != db.AnyVersion:
+			return s.writePreconditionFailed(w, id)
+		case errors.Is(err, db.ErrVersionMismatch):
+			return conflict(codeVersionMismatch, fmt.Sprintf("person with id %d was modified concurrently, retry the request", id))
+		}
+		return err
 	}
 
-	return WriteJson(w, http.StatusOK, "ok")
+	w.Header().Set("ETag", personETag(updated))
+	return WriteJson(w, http.StatusOK, updated)
 }
 
 // @Summary Обновление данных человека с обогащением
-// @Description Обновление записи о человеке с возможным обогащением данных в случае изменения имени
+// @Description Обновление записи о человеке с повторным обогащением данных в случае изменения имени. Не переданные поля сохраняют текущие значения; имена сравниваются в нормализованном виде, так что "ivan" для "Ivan" ничего не меняет
+// @Description Если меняются только фамилия или отчество, они записываются без запросов к внешним API
+// @Description Запись обновляется, только если она не изменилась, пока шли запросы к внешним API; иначе возвращается 409
 // @Tags people
 // @Accept  json
 // @Produce  json
 // @Param id path int true "ID человека"
 // @Param person body PersonReq true "Данные о человеке"
-// @Success 200 {object} SuccessResponse
-// @Failure 400 {object} ApiError
-// @Failure 404 {object} ApiError
-// @Failure 500 {object} ApiError
+// @Param If-Match header string false "ETag версии, на которой основано изменение; при несовпадении — 412"
+// @Success 200 {object} db.Person
+// @Failure 400 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 412 {object} db.Person "Запись изменилась: текущее представление"
+// @Failure 428 {object} Problem "If-Match обязателен (REQUIRE_IF_MATCH=true)"
+// @Failure 409 {object} Problem "Запись изменилась во время обновления"
+// @Failure 422 {object} Problem
+// @Failure 500 {object} Problem
+// @Failure 502 {object} Problem
 // @Router /people/enrich/{id} [put]
 func (s *APIServer) handleUpdatePeopleEnrich(w http.ResponseWriter, r *http.Request) error {
 	idStr := r.PathValue("id")
 
 	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return badRequest("invalid id")
+	}
 
+	version, err := s.ifMatchVersion(r)
 	if err != nil {
-		log.Printf("err fmt: %s", err)
-		WriteJson(w, http.StatusBadRequest, "bad request")
-		return nil
+		return err
 	}
 
 	person := new(PersonReq)
 	if err := json.NewDecoder(r.Body).Decode(person); err != nil {
-		WriteJson(w, http.StatusBadRequest, "bad request")
-		return nil
+		return badRequest("invalid request body")
 	}
 
-	currName, err := s.dbStorage.CheckName(id)
+	current, err := s.dbStorage.GetPerson(id)
 	if err != nil {
-		log.Printf("err at check: %s", err)
-		WriteJson(w, http.StatusInternalServerError, "internal server error")
-		return nil
-	}
-	if person.Name != "" && person.Name != currName {
-		results := FetchAPIS(person.Name)
-
-		processed, err := ProcessExtAPIs(results)
-
-		if err != nil {
-			return fmt.Errorf("failed to enrich data: %w", err)
+		if errors.Is(err, db.ErrPersonNotFound) || errors.Is(err, db.ErrPersonMerged) {
+			return personNotFound(id)
 		}
+		return internalError(err)
+	}
+	if version != db.AnyVersion && current.Version != version {
+		return s.writePreconditionFailed(w, id)
+	}
+	merged, changes, rename := planEnrichUpdate(current, *person)
+	if changes.Empty() {
+		w.Header().Set("ETag", personETag(current))
+		return WriteJson(w, http.StatusOK, current)
+	}
+	// What is validated is exactly what gets written.
+	if errs := validatePersonReq(merged); len(errs) > 0 {
+		return validationError("invalid person", errs)
+	}
 
-		var enrichedPerson PersonEnriched = PersonEnriched{
-			PersonReq:   *person,
-			Age:         processed["age"].(int),
-			Nationality: processed["country"].(string),
-			Gender:      processed["gender"].(string),
+	// The write only goes through if the person is still at the version
+	// read above, i.e. nobody renamed, patched or deleted it in the
+	// meantime; the external APIs in particular take a while.
+	var updated db.Person
+	if rename {
+		var enrichedPerson db.Person
+		if enrichedPerson, err = enrichPerson(merged); err != nil {
+			return upstreamError("failed to enrich person", err)
 		}
-		if err := s.dbStorage.UpdatePersonEnrich(id, enrichedPerson.PersonReq.Name, enrichedPerson.PersonReq.Surname, enrichedPerson.PersonReq.Patronymic, enrichedPerson.Age, enrichedPerson.Gender, enrichedPerson.Nationality); err != nil {
-			log.Printf("err at update: %s", err)
-
-			WriteJson(w, http.StatusNotFound, "internal server error")
-			return nil
+		updated, err = s.dbStorage.UpdatePersonEnrich(id, enrichedPerson, current.Version, changeFor(r, db.SourceEnrichment))
+	} else {
+		// The enrichment depends on the name alone, so it is kept.
+		updated, err = s.dbStorage.UpdatePersonPatch(id, changes, current.Version, changeFor(r, db.SourcePatch))
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrPersonNotFound):
+			return personNotFound(id)
+		case errors.Is(err, db.ErrVersionMismatch):
+			return conflict(codeVersionMismatch, fmt.Sprintf("person with id %d was modified during the update, retry the request", id))
 		}
+		return err
 	}
 
-	return WriteJson(w, http.StatusOK, "ok")
+	w.Header().Set("ETag", personETag(updated))
+	return WriteJson(w, http.StatusOK, updated)
 }
 
 // @Summary Удаление человека
-// @Description Удаление записи о человеке по ID
+// @Description Мягкое удаление записи о человеке по ID: запись исчезает из выдачи, но ее можно восстановить через POST /people/{id}/restore до истечения срока хранения
 // @Tags people
 // @Accept  json
 // @Produce  json
 // @Param id path int true "ID человека"
-// @Success 200 {object} SuccessResponse
-// @Failure 400 {object} ApiError
-// @Failure 404 {object} ApiError
-// @Failure 500 {object} ApiError
+// @Param If-Match header string false "ETag версии, на которой основано изменение; при несовпадении — 412"
+// @Success 204 "Запись удалена"
+// @Failure 400 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 412 {object} db.Person "Запись изменилась: текущее представление"
+// @Failure 428 {object} Problem "If-Match обязателен (REQUIRE_IF_MATCH=true)"
+// @Failure 500 {object} Problem
 // @Router /people/{id} [delete]
 func (s *APIServer) handleDeletePeople(w http.ResponseWriter, r *http.Request) error {
 	idStr := r.PathValue("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		log.Printf("err at delete: %s", err)
-		WriteJson(w, http.StatusBadRequest, "bad request")
-		return nil
+		return badRequest("invalid id")
 	}
 
-	if err := s.dbStorage.DeletePerson(id); err != nil {
-		log.Printf("err: %s", err)
-		WriteJson(w, http.StatusNotFound, "internal server error")
-		return nil
+	version, err := s.ifMatchVersion(r)
+	if err != nil {
+		return err
+	}
 
+	if err := s.dbStorage.DeletePerson(id, version, changeFor(r, db.SourceDelete)); err != nil {
+		switch {
+		case errors.Is(err, db.ErrVersionMismatch):
+			return s.writePreconditionFailed(w, id)
+		case errors.Is(err, db.ErrPersonNotFound):
+			return personNotFound(id)
+		}
+		return err
 	}
-	return WriteJson(w, http.StatusOK, "ok")
+	w.WriteHeader(http.StatusNoContent)
+	return nil
 }
 
-type ApiError struct {
-	Error string `json:"error" example:"error message"`
-}
+// @Summary Восстановление удаленного человека
+// @Description Отмена мягкого удаления записи о человеке. Для неудаленной записи ничего не меняет
+// @Tags people
+// @Accept  json
+// @Produce  json
+// @Param id path int true "ID человека"
+// @Success 200 {object} db.Person
+// @Failure 400 {object} Problem
+// @Failure 404 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /people/{id}/restore [post]
+func (s *APIServer) handleRestorePerson(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return badRequest("invalid id")
+	}
+
+	restored, err := s.dbStorage.RestorePerson(id, changeFor(r, db.SourceRestore))
+	if err != nil {
+		if errors.Is(err, db.ErrPersonNotFound) {
+			return personNotFound(id)
+		}
+		return internalError(err)
+	}
 
-type SuccessResponse struct {
-	Status string `json:"status" example:"success"`
+	w.Header().Set("ETag", personETag(restored))
+	return WriteJson(w, http.StatusOK, restored)
 }
@@ -0,0 +1,95 @@
+package api
+
+import (
+	db "db"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCreatePersonRejectsIncompleteEnrichment(t *testing.T) {
+	stubProviders(t,
+		respond(http.StatusOK, `{"age": null}`),
+		respond(http.StatusOK, `{"gender": "male", "probability": 0.9}`),
+		respond(http.StatusOK, `{"country": [{"country_id": "RU", "probability": 0.8}]}`),
+	)
+	stubCountries(t, "RU")
+	discardLogs(t)
+
+	// Nothing may reach the storage, which the zero server does not have.
+	s := &APIServer{}
+	req := httptest.NewRequest(http.MethodPost, "/people?on_duplicate=create", strings.NewReader(`{"name": "Zqwxcreate", "surname": "Ivanov"}`))
+	rec := httptest.NewRecorder()
+	makeHTTPHandleFunc(s.handleCreatePeople)(rec, req)
+
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusBadGateway, rec.Body)
+	}
+	var problem Problem
+	if err := json.NewDecoder(rec.Body).Decode(&problem); err != nil {
+		t.Fatal(err)
+	}
+	if problem.Code != codeUpstream {
+		t.Errorf("code = %q, want %q", problem.Code, codeUpstream)
+	}
+}
+
+func TestPlanEnrichUpdate(t *testing.T) {
+	current := db.Person{Name: "Иван", Surname: "Иванов", Patronymic: "Иванович"}
+
+	tests := []struct {
+		name        string
+		body        PersonReq
+		wantMerged  PersonReq
+		wantChanges db.PersonPatch
+		wantRename  bool
+	}{
+		{
+			name:       "empty body",
+			wantMerged: PersonReq{Name: "Иван", Surname: "Иванов", Patronymic: "Иванович"},
+		},
+		{
+			name:       "same name spelled differently",
+			body:       PersonReq{Name: "  иВАН "},
+			wantMerged: PersonReq{Name: "  иВАН ", Surname: "Иванов", Patronymic: "Иванович"},
+		},
+		{
+			name:        "surname only",
+			body:        PersonReq{Name: "иван", Surname: "Петров"},
+			wantMerged:  PersonReq{Name: "иван", Surname: "Петров", Patronymic: "Иванович"},
+			wantChanges: db.PersonPatch{Surname: strPtr("Петров")},
+		},
+		{
+			name:        "rename",
+			body:        PersonReq{Name: "Пётр", Patronymic: "петрович"},
+			wantMerged:  PersonReq{Name: "Пётр", Surname: "Иванов", Patronymic: "петрович"},
+			wantChanges: db.PersonPatch{Name: strPtr("Пётр"), Patronymic: strPtr("петрович")},
+			wantRename:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, changes, rename := planEnrichUpdate(current, tt.body)
+			if merged != tt.wantMerged || rename != tt.wantRename {
+				t.Errorf("merged, rename = %+v, %v, want %+v, %v", merged, rename, tt.wantMerged, tt.wantRename)
+			}
+			if !reflect.DeepEqual(changes, tt.wantChanges) {
+				t.Errorf("changes = %s, want %s", patchString(changes), patchString(tt.wantChanges))
+			}
+		})
+	}
+}
+
+func patchString(p db.PersonPatch) string {
+	deref := func(s *string) string {
+		if s == nil {
+			return "<nil>"
+		}
+		return *s
+	}
+	return fmt.Sprintf("{Name:%s Surname:%s Patronymic:%s}", deref(p.Name), deref(p.Surname), deref(p.Patronymic))
+}
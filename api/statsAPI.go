@@ -0,0 +1,68 @@
+package api
+
+import (
+	db "db"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+const (
+	maxStatsTopN        = 100
+	maxStatsBucketWidth = 100
+)
+
+// @Summary Статистика по людям
+// @Description Распределения по полу, национальности (top N и "other"), гистограмма возрастов и средний/медианный возраст по национальностям. Учитывает те же фильтры, что и GET /people. При source=materialized читается материализованное представление (фильтры только по nationality, gender, age); оно обновляется командой go run . -refresh-stats
+// @Tags people
+// @Accept  json
+// @Produce  json
+// @Param top query int false "Количество национальностей в топе (по умолчанию: 10)" default(10)
+// @Param bucket query int false "Ширина интервала гистограммы возрастов (по умолчанию: 10)" default(10)
+// @Param source query string false "Источник данных" Enums(live, materialized) default(live)
+// @Param filter[field][op] query string false "Типизированный фильтр, как в GET /people"
+// @Param include_deleted query bool false "Показать также удаленные записи (только для администраторов с заголовком X-Admin-Token)"
+// @Success 200 {object} db.PeopleStats
+// @Failure 400 {object} Problem
+// @Failure 403 {object} Problem
+// @Failure 500 {object} Problem
+// @Router /people/stats [get]
+func (s *APIServer) handleGetPeopleStats(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+
+	opts := db.StatsOptions{
+		Source:         db.StatsSource(query.Get("source")),
+		TopN:           parseIntPagination(query.Get("top"), 10),
+		AgeBucketWidth: parseIntPagination(query.Get("bucket"), 10),
+	}
+	if opts.Source == "" {
+		opts.Source = db.StatsLive
+	}
+	if opts.Source != db.StatsLive && opts.Source != db.StatsMaterialized {
+		return badRequest("source must be live or materialized")
+	}
+	if opts.TopN > maxStatsTopN {
+		return badRequest("%s", "top must be at most "+strconv.Itoa(maxStatsTopN))
+	}
+	if opts.AgeBucketWidth > maxStatsBucketWidth {
+		return badRequest("%s", "bucket must be at most "+strconv.Itoa(maxStatsBucketWidth))
+	}
+
+	filter, err := parsePeopleFilter(query)
+	if err != nil {
+		return badRequest("%s", err)
+	}
+	if filter.IncludeDeleted, err = s.includeDeleted(r); err != nil {
+		return err
+	}
+
+	stats, err := s.dbStorage.GetPeopleStats(filter, opts)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidQuery) {
+			return badRequest("%s", err)
+		}
+		return internalError(err)
+	}
+
+	return WriteJson(w, http.StatusOK, stats)
+}
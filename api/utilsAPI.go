@@ -2,12 +2,19 @@ package api
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	db "db"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -15,14 +22,13 @@ import (
 type APIServer struct {
 	listenAddr string
 	dbStorage  db.PostgresStorage
+	adminToken string
+	// requireIfMatch rejects writes to a person without If-Match.
+	requireIfMatch bool
 }
 
 type apiFunc func(http.ResponseWriter, *http.Request) error
 
-type apiErr struct {
-	Error string
-}
-
 type APIResponse struct {
 	API      string
 	Data     interface{}
@@ -43,7 +49,15 @@ func makeHTTPHandleFunc(f apiFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
-		err := f(w, r)
+		requestID := r.Header.Get("X-Request-ID")
+		if requestID == "" {
+			requestID = newRequestID()
+			r.Header.Set("X-Request-ID", requestID)
+		}
+		w.Header().Set("X-Request-ID", requestID)
+
+		sw := &statusWriter{ResponseWriter: w}
+		err := f(sw, r)
 		duration := time.Since(start)
 
 		requestLogs := map[string]interface{}{
@@ -52,6 +66,7 @@ func makeHTTPHandleFunc(f apiFunc) http.HandlerFunc {
 			"remote_addr": r.RemoteAddr,
 			"user_agent":  r.UserAgent(),
 			"duration":    duration.String(),
+			"request_id":  requestID,
 		}
 
 		if len(r.URL.Query()) > 0 {
@@ -60,18 +75,85 @@ func makeHTTPHandleFunc(f apiFunc) http.HandlerFunc {
 
 		if err != nil {
 			requestLogs["error"] = err.Error()
-			WriteJson(w, http.StatusBadRequest, apiErr{Error: err.Error()})
+			// A handler that has already started its response can only
+			// log the error.
+			if sw.status == 0 {
+				writeProblem(sw, r, err)
+			}
 		}
+		requestLogs["status"] = sw.status
 
 		log.Printf("incoming request: %+v", requestLogs)
 	}
 }
 
+// statusWriter records the status of the response, so that an error a
+// handler returns is not written on top of a response it has started.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// changeFor attributes a write to the X-Actor of the request and its request
+// id; every history row of the write carries both.
+func changeFor(r *http.Request, source db.ChangeSource) db.Change {
+	return db.Change{
+		Actor:     r.Header.Get("X-Actor"),
+		Source:    source,
+		RequestID: r.Header.Get("X-Request-ID"),
+	}
+}
+
 func NewAPIServer(listenAddr string, postgresDB db.PostgresStorage) *APIServer {
 	return &APIServer{
-		listenAddr: listenAddr,
-		dbStorage:  postgresDB,
+		listenAddr:     listenAddr,
+		dbStorage:      postgresDB,
+		adminToken:     os.Getenv("ADMIN_TOKEN"),
+		requireIfMatch: os.Getenv("REQUIRE_IF_MATCH") == "true",
+	}
+}
+
+// isAdmin reports whether the request carries the ADMIN_TOKEN in
+// X-Admin-Token. Without a configured token nobody is an admin.
+func (s *APIServer) isAdmin(r *http.Request) bool {
+	token := r.Header.Get("X-Admin-Token")
+	return s.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) == 1
+}
+
+// includeDeleted reads include_deleted. Soft-deleted people are shown to
+// admins only; anyone else asking for them gets a 403 error.
+func (s *APIServer) includeDeleted(r *http.Request) (bool, error) {
+	if r.URL.Query().Get("include_deleted") != "true" {
+		return false, nil
+	}
+	if !s.isAdmin(r) {
+		return false, requestError(http.StatusForbidden, codeForbidden, "include_deleted is only available to admins")
 	}
+	return true, nil
 }
 
 func NewRouter() *Router {
@@ -96,6 +178,13 @@ func FetchAPI(ctx context.Context, apiURL string, param string, resultChan chan<
 	}
 	defer resp.Body.Close()
 
+	// Rate limits (429) and other failures come with an error body that
+	// must not be read as a result.
+	if resp.StatusCode != http.StatusOK {
+		resultChan <- APIResponse{API: apiURL, APIError: fmt.Sprintf("unexpected status %d", resp.StatusCode)}
+		return
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		resultChan <- APIResponse{API: apiURL, APIError: err.Error()}
@@ -111,46 +200,48 @@ func FetchAPI(ctx context.Context, apiURL string, param string, resultChan chan<
 	resultChan <- APIResponse{API: apiURL, Data: data}
 }
 
+// ProcessExtAPIs collects age, gender and nationality from the provider
+// responses. A failed request or a malformed body is an error; fields a
+// provider does not know, which it reports as null, are left out, so the
+// result may be incomplete.
 func ProcessExtAPIs(responses []APIResponse) (map[string]interface{}, error) {
 	result := make(map[string]interface{})
 	for _, resp := range responses {
-		switch resp.API {
-		case "https://api.agify.io/":
-			data, ok := resp.Data.(map[string]interface{})
-			if !ok {
-				return nil, fmt.Errorf("unexpected result, map expected %T", resp.Data)
-			}
-
-			ageResp := AgeResp{
-				Age: int(data["age"].(float64)),
-			}
-			result["age"] = ageResp.Age
+		if resp.APIError != "" {
+			return nil, fmt.Errorf("%s: %s", resp.API, resp.APIError)
+		}
+		data, ok := resp.Data.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: unexpected result, map expected %T", resp.API, resp.Data)
+		}
 
-		case "https://api.genderize.io/":
-			data, ok := resp.Data.(map[string]interface{})
-			if !ok {
-				return nil, fmt.Errorf("unexpected result, map expected %T", resp.Data)
-			}
-			genderResp := GenderResp{
-				Gender:      data["gender"].(string),
-				Probability: data["probability"].(float64),
+		switch resp.API {
+		case agifyAPI:
+			if age, ok := data["age"].(float64); ok {
+				result["age"] = int(age)
 			}
-			result["gender"] = genderResp.Gender
-			result["gender_probability"] = genderResp.Probability
 
-		case "https://api.nationalize.io/":
-			data, ok := resp.Data.(map[string]interface{})
-			if !ok {
-				return nil, fmt.Errorf("unexpected result, map expected %T", resp.Data)
+		case genderizeAPI:
+			gender, _ := data["gender"].(string)
+			probability, _ := data["probability"].(float64)
+			if gender != "" {
+				result["gender"] = gender
+				result["gender_probability"] = probability
 			}
 
-			countries := make([]CountryRespMap, 0)
-			for _, c := range data["country"].([]interface{}) {
-				country := c.(map[string]interface{})
-				countries = append(countries, CountryRespMap{
-					CountryID:   country["country_id"].(string),
-					Probability: country["probability"].(float64),
-				})
+		case nationalizeAPI:
+			list, _ := data["country"].([]interface{})
+			countries := make([]CountryRespMap, 0, len(list))
+			for _, c := range list {
+				country, _ := c.(map[string]interface{})
+				id, _ := country["country_id"].(string)
+				probability, _ := country["probability"].(float64)
+				// nationalize.io also reports codes outside ISO 3166, e.g.
+				// XK for Kosovo; people can only refer to listed countries.
+				if !knownCountry(id) {
+					continue
+				}
+				countries = append(countries, CountryRespMap{CountryID: id, Probability: probability})
 			}
 
 			nationalizeResp := NationalityResp{
@@ -196,3 +287,70 @@ func FetchAPIS(name string) []APIResponse {
 	}
 	return responces
 }
+
+// personETag is the version of the person. Snapshots rebuilt from history
+// have no version and get a weak tag of their content instead.
+func personETag(p db.Person) string {
+	if p.Version > 0 {
+		return fmt.Sprintf(`"%d"`, p.Version)
+	}
+	body, _ := json.Marshal(p)
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf(`W/"%x"`, sum[:12])
+}
+
+// expandedETag tags the representation with an expanded nationality apart
+// from the plain one of the same version, e.g. "5-nationality", so that a
+// validator of one never matches the other.
+func expandedETag(etag string) string {
+	return strings.TrimSuffix(etag, `"`) + `-nationality"`
+}
+
+// ifMatchVersion reads the version a write is based on from If-Match. Without
+// the header, or with *, the write is unconditional unless If-Match is
+// required.
+func (s *APIServer) ifMatchVersion(r *http.Request) (int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		if s.requireIfMatch {
+			return 0, requestError(http.StatusPreconditionRequired, codePreconditionRequired, "If-Match header is required")
+		}
+		return db.AnyVersion, nil
+	}
+	if header == "*" {
+		return db.AnyVersion, nil
+	}
+	// Either representation of a version is a valid base for a write.
+	version, err := strconv.Atoi(strings.TrimSuffix(strings.Trim(header, `"`), "-nationality"))
+	if err != nil || version < 1 || !strings.HasPrefix(header, `"`) {
+		return 0, badRequest("If-Match must be a single strong ETag of the person or *")
+	}
+	return version, nil
+}
+
+// writePreconditionFailed answers a write based on a stale version with 412
+// and the current representation.
+func (s *APIServer) writePreconditionFailed(w http.ResponseWriter, id int) error {
+	current, err := s.dbStorage.GetPerson(id)
+	if err != nil {
+		return &APIError{Status: http.StatusPreconditionFailed, Code: codeVersionMismatch,
+			Detail: fmt.Sprintf("person with id %d was modified", id), Err: err}
+	}
+	w.Header().Set("ETag", personETag(current))
+	return WriteJson(w, http.StatusPreconditionFailed, current)
+}
+
+// etagMatches reports whether an If-None-Match / If-Match header value
+// matches etag, using weak comparison as If-None-Match requires.
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
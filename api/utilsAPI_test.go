@@ -0,0 +1,58 @@
+package api
+
+import (
+	db "db"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExpandedETag(t *testing.T) {
+	plain := personETag(db.Person{Version: 5})
+	expanded := expandedETag(plain)
+	if plain != `"5"` || expanded != `"5-nationality"` {
+		t.Fatalf("etags = %s, %s", plain, expanded)
+	}
+	if etagMatches(plain, expanded) || etagMatches(expanded, plain) {
+		t.Error("plain and expanded representations share a validator")
+	}
+	if weak := expandedETag(`W/"abc"`); weak != `W/"abc-nationality"` {
+		t.Errorf("weak etag = %s", weak)
+	}
+}
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		header     string
+		require    bool
+		want       int
+		wantStatus int
+	}{
+		{header: "", want: db.AnyVersion},
+		{header: "", require: true, wantStatus: http.StatusPreconditionRequired},
+		{header: "*", want: db.AnyVersion},
+		{header: `"5"`, want: 5},
+		{header: `"5-nationality"`, want: 5},
+		{header: `W/"5"`, wantStatus: http.StatusBadRequest},
+		{header: `"0"`, wantStatus: http.StatusBadRequest},
+		{header: `"abc"`, wantStatus: http.StatusBadRequest},
+		{header: `5`, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPatch, "/people/1", nil)
+		if tt.header != "" {
+			r.Header.Set("If-Match", tt.header)
+		}
+		s := &APIServer{requireIfMatch: tt.require}
+		got, err := s.ifMatchVersion(r)
+
+		var apiErr *APIError
+		switch {
+		case tt.wantStatus != 0 && !(errors.As(err, &apiErr) && apiErr.Status == tt.wantStatus):
+			t.Errorf("If-Match %s: err = %v, want status %d", tt.header, err, tt.wantStatus)
+		case tt.wantStatus == 0 && (err != nil || got != tt.want):
+			t.Errorf("If-Match %s: got %d, %v, want %d", tt.header, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package api
+
+import (
+	db "db"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FieldError is one failed validation rule of a request field.
+type FieldError struct {
+	Field   string `json:"field" example:"surname"`
+	Message string `json:"message" example:"must be at most 100 characters"`
+}
+
+func (e FieldError) String() string {
+	return e.Field + " " + e.Message
+}
+
+// fieldErrorMessages flattens errs for the per-row reports.
+func fieldErrorMessages(errs []FieldError) []string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.String()
+	}
+	return msgs
+}
+
+// validatePersonReq checks the fields a client sends to create a person.
+// Names are checked in the normalized form they are stored in, so stray
+// whitespace is not an error.
+func validatePersonReq(p PersonReq) []FieldError {
+	return validateStruct(normalizePersonReq(p))
+}
+
+// validatePerson checks a whole record, as it would be written.
+func validatePerson(p db.Person) []FieldError {
+	return validateStruct(PersonEnriched{
+		PersonReq:   normalizePersonReq(PersonReq{Name: p.Name, Surname: p.Surname, Patronymic: p.Patronymic}),
+		Age:         p.Age,
+		Gender:      p.Gender,
+		Nationality: p.Nationality,
+	})
+}
+
+func normalizePersonReq(p PersonReq) PersonReq {
+	return PersonReq{
+		Name:       db.NormalizeName(p.Name),
+		Surname:    db.NormalizeName(p.Surname),
+		Patronymic: db.NormalizeName(p.Patronymic),
+	}
+}
+
+// validateStruct checks v against the comma-separated rules in the validate
+// tags of its fields, which are named after their json tags:
+//
+//	required   the value is not empty
+//	min=N      ints: at least N
+//	max=N      ints: at most N; strings: at most N characters
+//	oneof=a b  one of the space-separated values
+//	script     letters of a single script, Latin or Cyrillic, optionally
+//	           separated by spaces, hyphens and apostrophes
+//	iso3166    an ISO 3166-1 alpha-2 country code listed in em_countries
+//
+// Rules other than required are not checked on empty values. Embedded
+// structs are checked as part of v.
+func validateStruct(v interface{}) []FieldError {
+	var errs []FieldError
+	rv := reflect.ValueOf(v)
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if f.Anonymous {
+			errs = append(errs, validateStruct(rv.Field(i).Interface())...)
+			continue
+		}
+		tag := f.Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if msg := checkRules(rv.Field(i), tag); msg != "" {
+			errs = append(errs, FieldError{Field: name, Message: msg})
+		}
+	}
+	return errs
+}
+
+var validationRules = map[string]bool{
+	"required": true, "min": true, "max": true, "oneof": true, "script": true, "iso3166": true,
+}
+
+// The validate tags are checked when the package is initialized, so a typo
+// in a rule fails at startup rather than on a request.
+func init() {
+	for _, v := range []interface{}{PersonReq{}, PersonEnriched{}} {
+		if err := checkValidateTags(reflect.TypeOf(v)); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// checkValidateTags reports the first rule in the validate tags of t,
+// including its embedded structs, that validateStruct does not know.
+func checkValidateTags(t reflect.Type) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			if err := checkValidateTags(f.Type); err != nil {
+				return err
+			}
+			continue
+		}
+		tag := f.Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+		for _, rule := range strings.Split(tag, ",") {
+			name, _, _ := strings.Cut(rule, "=")
+			if !validationRules[name] {
+				return fmt.Errorf("%s.%s: unknown validation rule %q", t.Name(), f.Name, name)
+			}
+		}
+	}
+	return nil
+}
+
+// checkRules returns the message of the first rule value breaks.
+func checkRules(value reflect.Value, rules string) string {
+	empty := value.IsZero()
+	if value.Kind() == reflect.String {
+		empty = strings.TrimSpace(value.String()) == ""
+	}
+
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		if name == "required" {
+			if empty {
+				return "is required"
+			}
+			continue
+		}
+		if empty {
+			return ""
+		}
+
+		switch name {
+		case "min", "max":
+			limit, _ := strconv.Atoi(arg)
+			if value.Kind() == reflect.String {
+				if name == "max" && utf8.RuneCountInString(value.String()) > limit {
+					return fmt.Sprintf("must be at most %d characters", limit)
+				}
+				continue
+			}
+			n := int(value.Int())
+			if (name == "min" && n < limit) || (name == "max" && n > limit) {
+				return fmt.Sprintf("must be between %s", intBounds(rules))
+			}
+		case "oneof":
+			if !slices.Contains(strings.Fields(arg), value.String()) {
+				return "must be one of " + strings.Join(strings.Fields(arg), ", ")
+			}
+		case "script":
+			if !singleScript(value.String()) {
+				return "must consist of Latin or Cyrillic letters, spaces, hyphens and apostrophes"
+			}
+		case "iso3166":
+			if !knownCountry(value.String()) {
+				return "must be an ISO 3166-1 alpha-2 country code"
+			}
+		default:
+			// Unreachable for the types checked by checkValidateTags.
+			return "has unknown validation rule " + name
+		}
+	}
+	return ""
+}
+
+// intBounds renders the min and max rules of a field as "1 and 199".
+func intBounds(rules string) string {
+	var lo, hi string
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "min":
+			lo = arg
+		case "max":
+			hi = arg
+		}
+	}
+	return lo + " and " + hi
+}
+
+// singleScript reports whether s is made of words in one script, Latin or
+// Cyrillic, separated by single spaces, hyphens or apostrophes. Mixing
+// scripts is rejected, as it is almost always a look-alike letter typed on
+// the wrong keyboard layout.
+func singleScript(s string) bool {
+	var script *unicode.RangeTable
+	sep := true
+	for _, r := range strings.TrimSpace(s) {
+		if r == ' ' || r == '-' || r == '\'' || r == '’' {
+			if sep {
+				return false
+			}
+			sep = true
+			continue
+		}
+
+		var rs *unicode.RangeTable
+		switch {
+		case !unicode.IsLetter(r):
+			return false
+		case unicode.Is(unicode.Latin, r):
+			rs = unicode.Latin
+		case unicode.Is(unicode.Cyrillic, r):
+			rs = unicode.Cyrillic
+		default:
+			return false
+		}
+		if script != nil && script != rs {
+			return false
+		}
+		script, sep = rs, false
+	}
+	return !sep
+}
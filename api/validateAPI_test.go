@@ -0,0 +1,162 @@
+package api
+
+import (
+	db "db"
+	"reflect"
+	"testing"
+)
+
+// stubCountries replaces the em_countries cache with codes for the duration
+// of the test.
+func stubCountries(t *testing.T, codes ...string) {
+	t.Helper()
+	countries := make([]db.Country, len(codes))
+	for i, code := range codes {
+		countries[i] = db.Country{Alpha2: code}
+	}
+
+	countryCache.mu.Lock()
+	saved, savedByAlpha2 := countryCache.countries, countryCache.byAlpha2
+	setCountries(countries)
+	countryCache.mu.Unlock()
+
+	t.Cleanup(func() {
+		countryCache.mu.Lock()
+		countryCache.countries, countryCache.byAlpha2 = saved, savedByAlpha2
+		countryCache.mu.Unlock()
+	})
+}
+
+func TestValidateNationalityAgainstCountries(t *testing.T) {
+	stubCountries(t, "RU", "KZ")
+
+	tests := []struct {
+		nationality string
+		wantErr     bool
+	}{
+		{"RU", false},
+		{"KZ", false},
+		{"", false},
+		{"ru", true},
+		{"XK", true},
+		{"RUS", true},
+	}
+	for _, tt := range tests {
+		p := db.Person{Name: "Ivan", Surname: "Ivanov", Age: 30, Gender: "male", Nationality: tt.nationality}
+		errs := validatePerson(p)
+		if gotErr := len(errs) > 0; gotErr != tt.wantErr {
+			t.Errorf("nationality %q: errors %v, want error %v", tt.nationality, errs, tt.wantErr)
+		}
+	}
+}
+
+func TestCheckValidateTags(t *testing.T) {
+	type inner struct {
+		Name string `json:"name" validate:"required,max=5"`
+	}
+	type typo struct {
+		inner
+		Age int `json:"age" validate:"required,mni=1"`
+	}
+
+	if err := checkValidateTags(reflect.TypeOf(inner{})); err != nil {
+		t.Errorf("valid tags: %v", err)
+	}
+	if err := checkValidateTags(reflect.TypeOf(typo{})); err == nil {
+		t.Error("unknown rule mni was not reported")
+	}
+}
+
+func TestValidatePersonReq(t *testing.T) {
+	long := func(n int) string {
+		s := make([]rune, n)
+		for i := range s {
+			s[i] = 'а'
+		}
+		return string(s)
+	}
+
+	tests := []struct {
+		name string
+		req  PersonReq
+		want []FieldError
+	}{
+		{name: "valid", req: PersonReq{Name: "Иван", Surname: "Иванов", Patronymic: "Иванович"}},
+		{name: "no patronymic", req: PersonReq{Name: "John", Surname: "Smith"}},
+		{name: "hyphen and apostrophe", req: PersonReq{Name: "Anna-Maria", Surname: "O'Neil"}},
+		{name: "stray whitespace is normalized away", req: PersonReq{Name: "  анна   мария ", Surname: "иванова"}},
+		{name: "length counts letters, not bytes", req: PersonReq{Name: long(50), Surname: long(100)}},
+		{
+			name: "missing required",
+			req:  PersonReq{Name: "  ", Patronymic: "Иванович"},
+			want: []FieldError{{"name", "is required"}, {"surname", "is required"}},
+		},
+		{
+			name: "too long",
+			req:  PersonReq{Name: long(51), Surname: "Иванов", Patronymic: long(101)},
+			want: []FieldError{{"name", "must be at most 50 characters"}, {"patronymic", "must be at most 100 characters"}},
+		},
+		{
+			name: "mixed scripts",
+			req:  PersonReq{Name: "Ивan", Surname: "Иванов"},
+			want: []FieldError{{"name", "must consist of Latin or Cyrillic letters, spaces, hyphens and apostrophes"}},
+		},
+		{
+			name: "digits",
+			req:  PersonReq{Name: "Иван", Surname: "12345"},
+			want: []FieldError{{"surname", "must consist of Latin or Cyrillic letters, spaces, hyphens and apostrophes"}},
+		},
+		{
+			name: "doubled separator",
+			req:  PersonReq{Name: "Anna--Maria", Surname: "Smith"},
+			want: []FieldError{{"name", "must consist of Latin or Cyrillic letters, spaces, hyphens and apostrophes"}},
+		},
+		{
+			name: "trailing separator",
+			req:  PersonReq{Name: "Anna-", Surname: "Smith"},
+			want: []FieldError{{"name", "must consist of Latin or Cyrillic letters, spaces, hyphens and apostrophes"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validatePersonReq(tt.req); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validatePersonReq(%+v) = %v, want %v", tt.req, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePerson(t *testing.T) {
+	stubCountries(t, "RU")
+	valid := db.Person{Name: "Иван", Surname: "Иванов", Age: 30, Gender: "male", Nationality: "RU"}
+
+	tests := []struct {
+		name   string
+		modify func(*db.Person)
+		want   []FieldError
+	}{
+		{name: "valid", modify: func(p *db.Person) {}},
+		{name: "youngest", modify: func(p *db.Person) { p.Age = 1 }},
+		{name: "oldest", modify: func(p *db.Person) { p.Age = 199 }},
+		{name: "unknown nationality is allowed", modify: func(p *db.Person) { p.Nationality = "" }},
+		{name: "age missing", modify: func(p *db.Person) { p.Age = 0 }, want: []FieldError{{"age", "is required"}}},
+		{name: "age negative", modify: func(p *db.Person) { p.Age = -1 }, want: []FieldError{{"age", "must be between 1 and 199"}}},
+		{name: "age too high", modify: func(p *db.Person) { p.Age = 200 }, want: []FieldError{{"age", "must be between 1 and 199"}}},
+		{name: "gender", modify: func(p *db.Person) { p.Gender = "other" }, want: []FieldError{{"gender", "must be one of male, female"}}},
+		{name: "gender missing", modify: func(p *db.Person) { p.Gender = "" }, want: []FieldError{{"gender", "is required"}}},
+		{
+			name:   "several fields",
+			modify: func(p *db.Person) { p.Surname, p.Nationality = "", "XX" },
+			want:   []FieldError{{"surname", "is required"}, {"nationality", "must be an ISO 3166-1 alpha-2 country code"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := valid
+			tt.modify(&p)
+			if got := validatePerson(p); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validatePerson = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
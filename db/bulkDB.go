@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type BulkAction string
+
+const (
+	BulkCreate BulkAction = "create"
+	BulkPatch  BulkAction = "patch"
+	BulkDelete BulkAction = "delete"
+)
+
+// BulkOp is one write of a bulk request. Create uses Person; patch and
+// delete use ID and Version, and patch also Patch.
+type BulkOp struct {
+	Action  BulkAction
+	ID      int
+	Version int
+	Person  Person
+	Patch   PersonPatch
+}
+
+// BulkOpError reports the operation that made a bulk write fail.
+type BulkOpError struct {
+	Index int
+	Err   error
+}
+
+func (e *BulkOpError) Error() string {
+	return fmt.Sprintf("operation %d: %s", e.Index, e.Err)
+}
+
+func (e *BulkOpError) Unwrap() error {
+	return e.Err
+}
+
+// errDryRun rolls back a bulk write that was only a preview.
+var errDryRun = errors.New("dry run")
+
+// ExecBulk runs ops in order in a single transaction and returns the
+// resulting person of each: the created or patched record, or the deleted
+// one's id. The first failing operation rolls back all of them and is
+// reported as a *BulkOpError. With dryRun the operations are run the same
+// way and then rolled back.
+func (s *PostgresStorage) ExecBulk(ops []BulkOp, dryRun bool, c Change) ([]Person, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	results := make([]Person, len(ops))
+	err := s.withChange(ctx, c, func(tx *sql.Tx) error {
+		for i, op := range ops {
+			var err error
+			switch op.Action {
+			case BulkCreate:
+				results[i], err = insertPerson(ctx, tx, op.Person)
+			case BulkPatch:
+				results[i], err = patchPerson(ctx, tx, op.ID, op.Patch, op.Version)
+			case BulkDelete:
+				results[i] = Person{ID: op.ID}
+				err = deletePerson(ctx, tx, op.ID, op.Version)
+			default:
+				err = fmt.Errorf("unknown action %q", op.Action)
+			}
+			if err != nil {
+				return &BulkOpError{Index: i, Err: err}
+			}
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ListBulkTargets returns up to limit live people matching the conditions of
+// filter, in id order; deleted people and as_of do not apply to writes.
+// Filtered bulk writes are run on this list with the listed versions as
+// preconditions, so rows changed since are not overwritten.
+func (s *PostgresStorage) ListBulkTargets(filter PeopleFilter, limit int) ([]Person, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	b := newPeopleSelect(personColumns, PeopleFilter{Conditions: filter.Conditions})
+	filter.apply(b)
+	b.order("id ASC")
+	b.limit = limit
+
+	query, args := b.build()
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list people: %w", err)
+	}
+	defer rows.Close()
+
+	var people []Person
+	for rows.Next() {
+		p, err := scanPerson(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan person: %w", err)
+		}
+		people = append(people, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list people: %w", err)
+	}
+	return people, nil
+}
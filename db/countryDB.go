@@ -0,0 +1,45 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Country is an ISO 3166-1 entry of the em_countries reference table that
+// people's nationality refers to.
+type Country struct {
+	Alpha2  string `json:"alpha2" example:"RU"`
+	Alpha3  string `json:"alpha3" example:"RUS"`
+	Numeric string `json:"numeric" example:"643"`
+	NameEn  string `json:"name_en" example:"Russia"`
+	NameRu  string `json:"name_ru" example:"Россия"`
+}
+
+// ListCountries returns every country, ordered by alpha-2 code.
+func (s *PostgresStorage) ListCountries() ([]Country, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		select alpha2, alpha3, numeric_code, name_en, name_ru
+		from em_countries
+		order by alpha2`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list countries: %w", err)
+	}
+	defer rows.Close()
+
+	countries := []Country{}
+	for rows.Next() {
+		var c Country
+		if err := rows.Scan(&c.Alpha2, &c.Alpha3, &c.Numeric, &c.NameEn, &c.NameRu); err != nil {
+			return nil, fmt.Errorf("failed to scan country: %w", err)
+		}
+		countries = append(countries, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list countries: %w", err)
+	}
+	return countries, nil
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// duplicateSimilarity is the minimum trigram similarity of full names for
+	// two records to be reported as likely duplicates.
+	duplicateSimilarity = 0.7
+	maxDuplicates       = 10
+)
+
+type DuplicateCandidate struct {
+	Person
+	Similarity float64 `json:"similarity"`
+}
+
+// FindDuplicates returns people that are likely the same as the given name:
+// an identical normalized name triple (similarity 1) or a full name at least
+// duplicateSimilarity similar. excludeID, when non-zero, is left out.
+func (s *PostgresStorage) FindDuplicates(name, surname, patronymic string, excludeID int) ([]DuplicateCandidate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	b := newPeopleSelect("", PeopleFilter{})
+	key := fmt.Sprintf("em_name_key(%s, %s, %s)", b.bind(name), b.bind(surname), b.bind(patronymic))
+	full := b.bind(name + " " + surname + " " + patronymic)
+	threshold := b.bind(duplicateSimilarity)
+
+	b.columns = fmt.Sprintf("%s, CASE WHEN name_key = %s THEN 1 ELSE similarity(%s, %s) END AS sim",
+		personColumns, key, fullNameExpr, full)
+	b.where(fmt.Sprintf("(name_key = %s OR (%s %% %s AND similarity(%s, %s) >= %s))",
+		key, fullNameExpr, full, fullNameExpr, full, threshold))
+	if excludeID != 0 {
+		b.where("id <> " + b.bind(excludeID))
+	}
+	b.order("sim DESC", "id ASC")
+	b.limit = maxDuplicates
+
+	query, args := b.build()
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find duplicates: %w", err)
+	}
+	defer rows.Close()
+
+	candidates := []DuplicateCandidate{}
+	for rows.Next() {
+		var c DuplicateCandidate
+		if c.Person, err = scanPerson(rows, &c.Similarity); err != nil {
+			return nil, fmt.Errorf("failed to scan duplicate: %w", err)
+		}
+		candidates = append(candidates, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to find duplicates: %w", err)
+	}
+
+	return candidates, nil
+}
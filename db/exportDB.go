@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+const exportFetchSize = 1000
+
+// ExportPeople streams every person matching filter to fn. Rows are read
+// through a server-side cursor in fixed-size chunks, so memory use does not
+// depend on the table size.
+func (s *PostgresStorage) ExportPeople(ctx context.Context, filter PeopleFilter, fn func(Person) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin export: %w", err)
+	}
+	defer tx.Rollback()
+
+	b := newPeopleSelect(personColumns, filter)
+	filter.apply(b)
+	b.order(DefaultSort.orderTerms(false)...)
+	query, args := b.build()
+	if _, err := tx.ExecContext(ctx, `DECLARE people_export NO SCROLL CURSOR FOR `+query, args...); err != nil {
+		return fmt.Errorf("failed to declare export cursor: %w", err)
+	}
+
+	fetch := fmt.Sprintf(`FETCH FORWARD %d FROM people_export`, exportFetchSize)
+	for {
+		rows, err := tx.QueryContext(ctx, fetch)
+		if err != nil {
+			return fmt.Errorf("failed to fetch export rows: %w", err)
+		}
+
+		n := 0
+		for rows.Next() {
+			p, err := scanPerson(rows)
+			if err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan export row: %w", err)
+			}
+			if err := fn(p); err != nil {
+				rows.Close()
+				return err
+			}
+			n++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to fetch export rows: %w", err)
+		}
+		if n < exportFetchSize {
+			break
+		}
+	}
+
+	return tx.Commit()
+}
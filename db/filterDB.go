@@ -0,0 +1,228 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type FilterOp string
+
+const (
+	OpEq       FilterOp = "eq"
+	OpNeq      FilterOp = "neq"
+	OpIn       FilterOp = "in"
+	OpPrefix   FilterOp = "prefix"
+	OpContains FilterOp = "contains"
+	OpGt       FilterOp = "gt"
+	OpGte      FilterOp = "gte"
+	OpLt       FilterOp = "lt"
+	OpLte      FilterOp = "lte"
+	OpIsNull   FilterOp = "is_null"
+)
+
+type fieldType int
+
+const (
+	fieldText fieldType = iota
+	fieldInt
+	fieldTime
+)
+
+type filterField struct {
+	column string
+	typ    fieldType
+	// name fields compare by NameLookupKey, against the em_lookup_key indexes.
+	name bool
+	// nullable fields may be missing and support is_null. Missing text is
+	// stored as '', e.g. an unknown nationality, and counts as null.
+	nullable bool
+}
+
+// filterableFields maps API field names to columns and their value types.
+var filterableFields = map[string]filterField{
+	"id":          {"id", fieldInt, false, false},
+	"name":        {"fname", fieldText, true, false},
+	"surname":     {"surname", fieldText, true, false},
+	"patronymic":  {"patronymic", fieldText, true, true},
+	"age":         {"age", fieldInt, false, false},
+	"gender":      {"gender", fieldText, false, false},
+	"nationality": {"nationality", fieldText, false, true},
+	"enriched_at": {"enriched_at", fieldTime, false, true},
+	"updated_at":  {"updated_at", fieldTime, false, false},
+}
+
+var allowedOps = map[fieldType][]FilterOp{
+	fieldText: {OpEq, OpNeq, OpIn, OpPrefix, OpContains, OpIsNull},
+	fieldInt:  {OpEq, OpNeq, OpIn, OpGt, OpGte, OpLt, OpLte, OpIsNull},
+	fieldTime: {OpEq, OpGt, OpGte, OpLt, OpLte, OpIsNull},
+}
+
+// Condition is one validated predicate on a person field. Values hold parsed,
+// typed operands: one for most operators, several for in.
+type Condition struct {
+	Field  string
+	Op     FilterOp
+	Values []interface{}
+}
+
+type PeopleFilter struct {
+	Conditions []Condition
+	// IncludeDeleted lists soft-deleted people alongside live ones.
+	IncludeDeleted bool
+	// AsOf, when set, filters the people as they were at that time,
+	// rebuilt from their history.
+	AsOf *time.Time
+}
+
+// ParseCondition validates op against the field type and parses raw into
+// typed values. For in, raw is a comma-separated list; for is_null it is
+// "true" or "false".
+func ParseCondition(field string, op FilterOp, raw string) (Condition, error) {
+	f, ok := filterableFields[field]
+	if !ok {
+		return Condition{}, fmt.Errorf("%w: cannot filter by %q", ErrInvalidQuery, field)
+	}
+	if !opAllowed(f.typ, op) || (op == OpIsNull && !f.nullable) {
+		return Condition{}, fmt.Errorf("%w: operator %q is not supported for %q", ErrInvalidQuery, op, field)
+	}
+
+	cond := Condition{Field: field, Op: op}
+	if op == OpIsNull {
+		isNull, err := strconv.ParseBool(raw)
+		if err != nil {
+			return Condition{}, fmt.Errorf("%w: %s[is_null] must be true or false", ErrInvalidQuery, field)
+		}
+		cond.Values = []interface{}{isNull}
+		return cond, nil
+	}
+
+	raws := []string{raw}
+	if op == OpIn {
+		raws = strings.Split(raw, ",")
+	}
+	for _, r := range raws {
+		v, err := parseFilterValue(f.typ, strings.TrimSpace(r))
+		if err != nil {
+			return Condition{}, fmt.Errorf("%w: %s: %v", ErrInvalidQuery, field, err)
+		}
+		cond.Values = append(cond.Values, v)
+	}
+	return cond, nil
+}
+
+func (f *PeopleFilter) Add(cond Condition) {
+	f.Conditions = append(f.Conditions, cond)
+}
+
+func opAllowed(typ fieldType, op FilterOp) bool {
+	for _, allowed := range allowedOps[typ] {
+		if allowed == op {
+			return true
+		}
+	}
+	return false
+}
+
+func parseFilterValue(typ fieldType, raw string) (interface{}, error) {
+	switch typ {
+	case fieldInt:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", raw)
+		}
+		return n, nil
+	case fieldTime:
+		for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+			if t, err := time.Parse(layout, raw); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not an RFC 3339 timestamp or date", raw)
+	}
+	if raw == "" {
+		return nil, fmt.Errorf("value must not be empty")
+	}
+	return raw, nil
+}
+
+// escapeLike escapes LIKE wildcards so user input is matched literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func (f PeopleFilter) apply(b *selectBuilder) {
+	for _, c := range f.Conditions {
+		b.where(c.sql(b))
+	}
+}
+
+// sql renders the condition. Text comparisons are case-insensitive; names
+// are compared by their lookup keys, so spacing and Ё do not matter either,
+// for pattern matches as much as for equality.
+func (c Condition) sql(b *selectBuilder) string {
+	field := filterableFields[c.Field]
+	column := field.column
+	text := field.typ == fieldText
+	textKey := strings.ToLower
+	switch {
+	case field.name:
+		column = "em_lookup_key(" + column + ")"
+		textKey = NameLookupKey
+	case text:
+		column = "lower(" + column + ")"
+	}
+	operand := func() string {
+		if text {
+			return b.bind(textKey(c.Values[0].(string)))
+		}
+		return b.bind(c.Values[0])
+	}
+
+	switch c.Op {
+	case OpEq:
+		return column + " = " + operand()
+	case OpNeq:
+		return column + " <> " + operand()
+	case OpGt:
+		return column + " > " + operand()
+	case OpGte:
+		return column + " >= " + operand()
+	case OpLt:
+		return column + " < " + operand()
+	case OpLte:
+		return column + " <= " + operand()
+	case OpPrefix:
+		return fmt.Sprintf("%s LIKE %s || '%%'", column, b.bind(escapeLike(textKey(c.Values[0].(string)))))
+	case OpContains:
+		return fmt.Sprintf("%s LIKE '%%' || %s || '%%'", column, b.bind(escapeLike(textKey(c.Values[0].(string)))))
+	case OpIsNull:
+		isNull := c.Values[0].(bool)
+		switch {
+		case text && isNull:
+			return field.column + " = ''"
+		case text:
+			return field.column + " <> ''"
+		case isNull:
+			return field.column + " IS NULL"
+		}
+		return field.column + " IS NOT NULL"
+	case OpIn:
+		if text {
+			values := make([]string, len(c.Values))
+			for i, v := range c.Values {
+				values[i] = textKey(v.(string))
+			}
+			return column + " = ANY(" + b.bind(pq.Array(values)) + "::text[])"
+		}
+		values := make([]int64, len(c.Values))
+		for i, v := range c.Values {
+			values[i] = int64(v.(int))
+		}
+		return column + " = ANY(" + b.bind(pq.Array(values)) + ")"
+	}
+	return "true"
+}
@@ -0,0 +1,123 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		field   string
+		op      FilterOp
+		raw     string
+		want    []interface{}
+		wantErr bool
+	}{
+		{field: "age", op: OpGte, raw: "18", want: []interface{}{18}},
+		{field: "age", op: OpIn, raw: "18, 21,30", want: []interface{}{18, 21, 30}},
+		{field: "nationality", op: OpIn, raw: "RU,UA", want: []interface{}{"RU", "UA"}},
+		{field: "surname", op: OpPrefix, raw: "Ив", want: []interface{}{"Ив"}},
+		{field: "nationality", op: OpIsNull, raw: "false", want: []interface{}{false}},
+		{field: "enriched_at", op: OpIsNull, raw: "true", want: []interface{}{true}},
+		{field: "updated_at", op: OpLt, raw: "2024-05-01", want: []interface{}{time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)}},
+		{field: "updated_at", op: OpGt, raw: "2024-05-01T10:00:00Z", want: []interface{}{time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)}},
+
+		{field: "password", op: OpEq, raw: "x", wantErr: true},
+		{field: "age", op: OpContains, raw: "1", wantErr: true},
+		{field: "name", op: OpGt, raw: "a", wantErr: true},
+		{field: "updated_at", op: OpIn, raw: "2024-05-01", wantErr: true},
+		{field: "age", op: OpEq, raw: "old", wantErr: true},
+		{field: "age", op: OpIn, raw: "1,,2", wantErr: true},
+		{field: "name", op: OpEq, raw: "", wantErr: true},
+		{field: "nationality", op: OpIsNull, raw: "maybe", wantErr: true},
+		{field: "gender", op: OpIsNull, raw: "true", wantErr: true},
+		{field: "age", op: OpIsNull, raw: "true", wantErr: true},
+		{field: "updated_at", op: OpIsNull, raw: "true", wantErr: true},
+		{field: "updated_at", op: OpGt, raw: "yesterday", wantErr: true},
+	}
+	for _, tt := range tests {
+		cond, err := ParseCondition(tt.field, tt.op, tt.raw)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidQuery) {
+				t.Errorf("ParseCondition(%s, %s, %q) error = %v, want ErrInvalidQuery", tt.field, tt.op, tt.raw, err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(cond.Values, tt.want) {
+			t.Errorf("ParseCondition(%s, %s, %q) = %v, %v, want %v", tt.field, tt.op, tt.raw, cond.Values, err, tt.want)
+		}
+	}
+}
+
+func TestConditionSQL(t *testing.T) {
+	tests := []struct {
+		field    string
+		op       FilterOp
+		raw      string
+		wantSQL  string
+		wantArgs []interface{}
+	}{
+		{"age", OpGte, "18", "age >= $1", []interface{}{18}},
+		{"age", OpIn, "18,21", "age = ANY($1)", []interface{}{pq.Array([]int64{18, 21})}},
+		{"gender", OpEq, "Male", "lower(gender) = $1", []interface{}{"male"}},
+		{"nationality", OpIn, "ru,UA", "lower(nationality) = ANY($1::text[])", []interface{}{pq.Array([]string{"ru", "ua"})}},
+		{"gender", OpContains, "Ale", "lower(gender) LIKE '%' || $1 || '%'", []interface{}{"ale"}},
+
+		// Missing values: NULL for timestamps, '' for text.
+		{"enriched_at", OpIsNull, "true", "enriched_at IS NULL", nil},
+		{"enriched_at", OpIsNull, "false", "enriched_at IS NOT NULL", nil},
+		{"nationality", OpIsNull, "true", "nationality = ''", nil},
+		{"patronymic", OpIsNull, "false", "patronymic <> ''", nil},
+
+		// Names are matched by lookup key: normalized, lower case, Ё as Е.
+		{"surname", OpEq, "  ЁЛКИНА ", "em_lookup_key(surname) = $1", []interface{}{"елкина"}},
+		{"name", OpNeq, "анна-мария", "em_lookup_key(fname) <> $1", []interface{}{"анна-мария"}},
+		{"patronymic", OpIn, "Пётрович,ИВАНОВИЧ", "em_lookup_key(patronymic) = ANY($1::text[])", []interface{}{pq.Array([]string{"петрович", "иванович"})}},
+		{"surname", OpPrefix, "50%_off", "em_lookup_key(surname) LIKE $1 || '%'", []interface{}{`50\%\_off`}},
+		{"surname", OpContains, "ВАН", "em_lookup_key(surname) LIKE '%' || $1 || '%'", []interface{}{"ван"}},
+		{"name", OpPrefix, "Ё", "em_lookup_key(fname) LIKE $1 || '%'", []interface{}{"е"}},
+		{"name", OpPrefix, "Е", "em_lookup_key(fname) LIKE $1 || '%'", []interface{}{"е"}},
+	}
+	for _, tt := range tests {
+		cond, err := ParseCondition(tt.field, tt.op, tt.raw)
+		if err != nil {
+			t.Fatalf("ParseCondition(%s, %s, %q): %v", tt.field, tt.op, tt.raw, err)
+		}
+		b := newSelect("id", "em_people1")
+		if got := cond.sql(b); got != tt.wantSQL {
+			t.Errorf("%s[%s]=%q: sql = %s, want %s", tt.field, tt.op, tt.raw, got, tt.wantSQL)
+		}
+		if !reflect.DeepEqual(b.args, tt.wantArgs) {
+			t.Errorf("%s[%s]=%q: args = %#v, want %#v", tt.field, tt.op, tt.raw, b.args, tt.wantArgs)
+		}
+	}
+}
+
+func TestPeopleFilterApply(t *testing.T) {
+	var f PeopleFilter
+	for _, c := range []struct {
+		field, raw string
+		op         FilterOp
+	}{{"age", "18", OpGte}, {"nationality", "RU", OpEq}} {
+		cond, err := ParseCondition(c.field, c.op, c.raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Add(cond)
+	}
+
+	b := newSelect("id", "em_people1")
+	f.apply(b)
+	query, args := b.build()
+	want := "SELECT id FROM em_people1 WHERE age >= $1 AND lower(nationality) = $2"
+	if query != want {
+		t.Errorf("query =\n%s\nwant\n%s", query, want)
+	}
+	if !reflect.DeepEqual(args, []interface{}{18, "ru"}) {
+		t.Errorf("args = %v", args)
+	}
+}
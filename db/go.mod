@@ -12,6 +12,7 @@ require (
 	github.com/lib/pq v1.10.9
 	github.com/mattes/migrate v3.0.1+incompatible
 	github.com/pressly/goose/v3 v3.24.3
+	golang.org/x/text v0.25.0
 )
 
 require (
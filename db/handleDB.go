@@ -3,69 +3,94 @@ package db
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
+	"unicode"
 )
 
+var ErrPersonNotFound = errors.New("person not found")
+
+// ErrVersionMismatch means the person changed since the version a
+// conditional write was based on.
+var ErrVersionMismatch = errors.New("person was modified concurrently")
+
+// AnyVersion makes a write unconditional.
+const AnyVersion = 0
+
 type Storage interface {
-	CreatePerson(string, string, string, int, string, string) error
-	DeletePerson(int) error
-	UpdatePersonEnrich(int, string, string, string, int, string, string) (string, error)
-	UpdatePersonPatch(int, string, string, string, int, string, string) (string, error)
-	CheckName(int) (string, error)
+	GetPerson(int) (Person, error)
+	CreatePerson(Person, Change) (Person, error)
+	CreatePeople([]Person, Change) ([]int, error)
+	DeletePerson(int, int, Change) error
+	UpdatePersonEnrich(int, Person, int, Change) (Person, error)
+	UpdatePersonPatch(int, PersonPatch, int, Change) (Person, error)
+	ExecBulk([]BulkOp, bool, Change) ([]Person, error)
 }
 
 type Person struct {
-	ID          int
-	Name        string
-	Surname     string
-	Patronymic  string
-	Age         int
-	Gender      string
-	Nationality string
-}
-
-func (s *PostgresStorage) GetPeopleWithPagination(fname, surname, patronymic string, age int, nationality, gender string, limit, offset int) ([]Person, int, error) {
-	query := `SELECT id, fname, surname, patronymic, age, nationality, gender FROM em_people1 WHERE 1=1`
-	countQuery := `SELECT count(*) FROM em_people1 WHERE 1=1`
-	var args []interface{}
-	var countArgs []interface{}
-	argCount := 1
-
-	addFilter := func(field, value string) {
-		query += fmt.Sprintf(" AND %s ILIKE '%%' || $%d || '%%'", field, argCount)
-		countQuery += fmt.Sprintf(" AND %s ILIKE '%%' || $%d || '%%'", field, argCount)
-		args = append(args, value)
-		countArgs = append(countArgs, value)
-		argCount++
-	}
+	ID                     int        `json:"id"`
+	Name                   string     `json:"name"`
+	Surname                string     `json:"surname"`
+	Patronymic             string     `json:"patronymic"`
+	Age                    int        `json:"age"`
+	Gender                 string     `json:"gender"`
+	Nationality            string     `json:"nationality"`
+	GenderProbability      float64    `json:"gender_probability"`
+	NationalityProbability float64    `json:"nationality_probability"`
+	EnrichedAt             *time.Time `json:"enriched_at"`
+	UpdatedAt              time.Time  `json:"updated_at"`
+	DeletedAt              *time.Time `json:"deleted_at,omitempty"`
+	Version                int        `json:"version,omitempty"`
+	Score                  *float64   `json:"score,omitempty"`
+}
 
-	if fname != "" {
-		addFilter("fname", fname)
-	}
-	if surname != "" {
-		addFilter("surname", surname)
-	}
-	if patronymic != "" {
-		addFilter("patronymic", patronymic)
-	}
-	if age > 0 {
-		query += fmt.Sprintf(" AND age = $%d", argCount)
-		countQuery += fmt.Sprintf(" AND age = $%d", argCount)
-		args = append(args, age)
-		countArgs = append(countArgs, age)
-		argCount++
+const personColumns = `id, fname, surname, patronymic, age, nationality, gender,
+	gender_probability, nationality_probability, enriched_at, updated_at, deleted_at, version`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanPerson scans personColumns followed by any extra selected columns.
+func scanPerson(row rowScanner, extra ...interface{}) (Person, error) {
+	var p Person
+	dest := []interface{}{&p.ID, &p.Name, &p.Surname, &p.Patronymic, &p.Age, &p.Nationality, &p.Gender,
+		&p.GenderProbability, &p.NationalityProbability, &p.EnrichedAt, &p.UpdatedAt, &p.DeletedAt, &p.Version}
+	err := row.Scan(append(dest, extra...)...)
+	return p, err
+}
+
+func (s *PostgresStorage) GetPerson(id int) (Person, error) {
+	query := `SELECT ` + personColumns + `, merged_into FROM em_people1 WHERE id = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var mergedInto *int
+	p, err := scanPerson(s.db.QueryRowContext(ctx, query, id), &mergedInto)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Person{}, fmt.Errorf("person with id %d: %w", id, ErrPersonNotFound)
+		}
+		return Person{}, fmt.Errorf("failed to get person: %w", err)
 	}
-	if nationality != "" {
-		addFilter("nationality", nationality)
+	if mergedInto != nil {
+		return Person{}, &MergedError{ID: id, SurvivorID: *mergedInto}
 	}
-	if gender != "" {
-		addFilter("gender", gender)
+	if p.DeletedAt != nil {
+		return Person{}, fmt.Errorf("person with id %d: %w", id, ErrPersonNotFound)
 	}
+	return p, nil
+}
 
-	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", argCount, argCount+1)
-	args = append(args, limit, offset)
+func (s *PostgresStorage) GetPeopleWithPagination(filter PeopleFilter, sort Sort, limit, offset int) ([]Person, int, error) {
+	b := newPeopleSelect(personColumns, filter)
+	filter.apply(b)
+	b.order(sort.orderTerms(false)...)
+	b.limit, b.offset = limit, offset
 
+	query, args := b.build()
 	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, 0, err
@@ -74,13 +99,14 @@ func (s *PostgresStorage) GetPeopleWithPagination(fname, surname, patronymic str
 
 	var people []Person
 	for rows.Next() {
-		var p Person
-		if err := rows.Scan(&p.ID, &p.Name, &p.Surname, &p.Patronymic, &p.Age, &p.Nationality, &p.Gender); err != nil {
+		p, err := scanPerson(rows)
+		if err != nil {
 			return nil, 0, err
 		}
 		people = append(people, p)
 	}
 
+	countQuery, countArgs := b.countSQL()
 	var total int
 	if err := s.db.QueryRow(countQuery, countArgs...).Scan(&total); err != nil {
 		return nil, 0, err
@@ -89,39 +115,221 @@ func (s *PostgresStorage) GetPeopleWithPagination(fname, surname, patronymic str
 	return people, total, nil
 }
 
-func (s *PostgresStorage) CreatePerson(name, surname, patronymic string, age int, gender, nationality string) error {
+type PersonSearchHit struct {
+	Person
+	Highlight string `json:"highlight"`
+}
+
+// searchPrefixQuery turns free text into a simple-config tsquery that matches
+// every word as a prefix, so partially typed names still hit.
+func searchPrefixQuery(q string) string {
+	var terms []string
+	for _, word := range strings.FieldsFunc(q, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		terms = append(terms, strings.ToLower(word)+":*")
+	}
+	return strings.Join(terms, " & ")
+}
+
+// SearchPeopleFullText runs a full-text search over fname, surname and
+// patronymic. Words are matched with Russian stemming or as prefixes; hits
+// are ranked with ts_rank and returned with the matches highlighted.
+func (s *PostgresStorage) SearchPeopleFullText(q string, filter PeopleFilter, limit, offset int) ([]PersonSearchHit, int, error) {
+	prefix := searchPrefixQuery(q)
+	if prefix == "" {
+		return nil, 0, fmt.Errorf("%w: search query has no words", ErrInvalidQuery)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
+	b := newPeopleSelect("", filter)
+	tsq := fmt.Sprintf("(websearch_to_tsquery('russian', %s) || to_tsquery('simple', %s))", b.bind(q), b.bind(prefix))
+	b.columns = fmt.Sprintf(`%s, ts_rank(search_tsv, %[2]s) AS rank,
+		ts_headline('russian', %[3]s, %[2]s, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true')`,
+		personColumns, tsq, fullNameExpr)
+	b.where("search_tsv @@ " + tsq)
+	filter.apply(b)
+	b.order("rank DESC", "id ASC")
+	b.limit, b.offset = limit, offset
+
+	query, args := b.build()
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search people: %w", err)
+	}
+	defer rows.Close()
+
+	var hits []PersonSearchHit
+	for rows.Next() {
+		var rank float64
+		var highlight string
+		p, err := scanPerson(rows, &rank, &highlight)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan person: %w", err)
+		}
+		p.Score = &rank
+		hits = append(hits, PersonSearchHit{Person: p, Highlight: highlight})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to search people: %w", err)
+	}
+
+	countQuery, countArgs := b.countSQL()
+	var total int
+	if err := s.db.QueryRowContext(ctx, countQuery, countArgs...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count people: %w", err)
+	}
+
+	return hits, total, nil
+}
+
+func (s *PostgresStorage) CreatePerson(p Person, c Change) (Person, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var created Person
+	err := s.withChange(ctx, c, func(tx *sql.Tx) error {
+		var err error
+		created, err = insertPerson(ctx, tx, p)
+		return err
+	})
+
+	if err != nil {
+		return Person{}, fmt.Errorf("failed to create person: %w", err)
+	}
+
+	return created, nil
+}
+
+// normalizeNames returns p with its names in stored form, see NormalizeName.
+func normalizeNames(p Person) Person {
+	p.Name = NormalizeName(p.Name)
+	p.Surname = NormalizeName(p.Surname)
+	p.Patronymic = NormalizeName(p.Patronymic)
+	return p
+}
+
+func insertPerson(ctx context.Context, tx *sql.Tx, p Person) (Person, error) {
 	query := `
 		insert into em_people1 
-		(fname, surname, patronymic, age, nationality, gender) 
-		values ($1, $2, $3, $4, $5, $6)
+		(fname, surname, patronymic, age, nationality, gender,
+		 gender_probability, nationality_probability, enriched_at,
+		 fname_phonetic, surname_phonetic, patronymic_phonetic,
+		 raw_fname, raw_surname, raw_patronymic) 
+		values ($1, $2, $3, $4, $5, $6, $7, $8, now(), $9, $10, $11, $12, $13, $14)
+		returning ` + personColumns + `
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	raw := p
+	p = normalizeNames(p)
+	return scanPerson(tx.QueryRowContext(ctx, query,
+		p.Name,
+		p.Surname,
+		p.Patronymic,
+		p.Age,
+		p.Nationality,
+		p.Gender,
+		p.GenderProbability,
+		p.NationalityProbability,
+		phoneticKey(p.Name),
+		phoneticKey(p.Surname),
+		phoneticKey(p.Patronymic),
+		raw.Name,
+		raw.Surname,
+		raw.Patronymic,
+	))
+}
 
-	_, err := s.db.ExecContext(ctx, query,
-		name,
-		surname,
-		patronymic,
-		age,
-		nationality,
-		gender,
-	)
+const insertBatchSize = 500
+
+// CreatePeople inserts all people in a single transaction using multi-row
+// inserts and returns the generated ids in input order.
+func (s *PostgresStorage) CreatePeople(people []Person, c Change) ([]int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
 
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to create person: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	return nil
+	if err := setChange(ctx, tx, c); err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, 0, len(people))
+	for start := 0; start < len(people); start += insertBatchSize {
+		batch := people[start:min(start+insertBatchSize, len(people))]
+
+		query := `
+		insert into em_people1
+		(fname, surname, patronymic, age, nationality, gender,
+		 gender_probability, nationality_probability, enriched_at,
+		 fname_phonetic, surname_phonetic, patronymic_phonetic,
+		 raw_fname, raw_surname, raw_patronymic)
+		values `
+		args := make([]interface{}, 0, len(batch)*14)
+		for i, raw := range batch {
+			if i > 0 {
+				query += ","
+			}
+			n := i * 14
+			query += fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, now(), $%d, $%d, $%d, $%d, $%d, $%d)",
+				n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10, n+11, n+12, n+13, n+14)
+			p := normalizeNames(raw)
+			args = append(args, p.Name, p.Surname, p.Patronymic, p.Age, p.Nationality, p.Gender,
+				p.GenderProbability, p.NationalityProbability,
+				phoneticKey(p.Name), phoneticKey(p.Surname), phoneticKey(p.Patronymic),
+				raw.Name, raw.Surname, raw.Patronymic)
+		}
+		query += " returning id"
+
+		rows, err := tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create people: %w", err)
+		}
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to create people: %w", err)
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to create people: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit people: %w", err)
+	}
+
+	return ids, nil
 }
 
-func (s *PostgresStorage) DeletePerson(id int) error {
-	query := `DELETE FROM em_people1 WHERE id = $1`
+// DeletePerson soft-deletes the person: the row stays until PurgeDeleted and
+// can be brought back with RestorePerson. Unless version is AnyVersion, the
+// person must still be at that version.
+func (s *PostgresStorage) DeletePerson(id, version int, c Change) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	result, err := s.db.ExecContext(ctx, query, id)
+	return s.withChange(ctx, c, func(tx *sql.Tx) error {
+		return deletePerson(ctx, tx, id, version)
+	})
+}
+
+// deletePerson soft-deletes a live person, if still at version.
+func deletePerson(ctx context.Context, tx *sql.Tx, id, version int) error {
+	query := `UPDATE em_people1 SET deleted_at = now(), updated_at = now(), version = version + 1
+		WHERE id = $1 AND ` + versionCond("$2") + ` AND ` + livePeople
+
+	result, err := tx.ExecContext(ctx, query, id, version)
 	if err != nil {
 		return fmt.Errorf("failed to delete person: %w", err)
 	}
@@ -132,99 +340,229 @@ func (s *PostgresStorage) DeletePerson(id int) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("person with id %d does not exist", id)
+		return missedWrite(ctx, tx, id)
 	}
 
 	return nil
 }
 
-func (s *PostgresStorage) UpdatePersonEnrich(id int, name, surname, patronymic string, age int, gender, nationality string) error {
+// RestorePerson undoes a soft delete. Restoring a person that is not deleted
+// is a no-op.
+func (s *PostgresStorage) RestorePerson(id int, c Change) (Person, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	_, err := s.db.ExecContext(ctx, `
+	var restored Person
+	err := s.withChange(ctx, c, func(tx *sql.Tx) error {
+		var err error
+		restored, err = scanPerson(tx.QueryRowContext(ctx, `
+			update em_people1 set
+				deleted_at = null,
+				updated_at = case when deleted_at is null then updated_at else now() end,
+				version = case when deleted_at is null then version else version + 1 end
+			where id = $1 and `+notMerged+`
+			returning `+personColumns, id))
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Person{}, fmt.Errorf("person with id %d: %w", id, ErrPersonNotFound)
+		}
+		return Person{}, fmt.Errorf("failed to restore person: %w", err)
+	}
+	return restored, nil
+}
+
+// UpdatePersonEnrich writes a renamed and re-enriched person. Callers enrich
+// before calling it and pass the version they read; since every change bumps
+// the version, the write is refused with ErrVersionMismatch if the name or
+// anything else changed in between, and with ErrPersonNotFound if the person
+// was deleted or merged.
+func (s *PostgresStorage) UpdatePersonEnrich(id int, p Person, version int, c Change) (Person, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	n := normalizeNames(p)
+	var updated Person
+	err := s.withChange(ctx, c, func(tx *sql.Tx) error {
+		var err error
+		updated, err = scanPerson(tx.QueryRowContext(ctx, `
         update em_people1 set
             fname = $1,
             surname = $2,
             patronymic = $3,
             age = $4,
             gender = $5,
-            nationality = $6
-        where id = $7
-    `, name, surname, patronymic, age, gender, nationality, id)
+            nationality = $6,
+            gender_probability = $7,
+            nationality_probability = $8,
+            fname_phonetic = $9,
+            surname_phonetic = $10,
+            patronymic_phonetic = $11,
+            raw_fname = $12,
+            raw_surname = $13,
+            raw_patronymic = $14,
+            enriched_at = now(),
+            updated_at = now(),
+            version = version + 1
+        where id = $15 and `+versionCond("$16")+` and `+livePeople+`
+        returning `+personColumns,
+			n.Name, n.Surname, n.Patronymic, n.Age, n.Gender, n.Nationality,
+			n.GenderProbability, n.NationalityProbability,
+			phoneticKey(n.Name), phoneticKey(n.Surname), phoneticKey(n.Patronymic),
+			p.Name, p.Surname, p.Patronymic, id, version))
+		if err == sql.ErrNoRows {
+			return missedWrite(ctx, tx, id)
+		}
+		return err
+	})
 
 	if err != nil {
-		return fmt.Errorf("failed to update person: %w", err)
+		if errors.Is(err, ErrPersonNotFound) || errors.Is(err, ErrVersionMismatch) {
+			return Person{}, err
+		}
+		return Person{}, fmt.Errorf("failed to update person: %w", err)
 	}
 
-	return nil
+	return updated, nil
 }
 
-func (s *PostgresStorage) CheckName(id int) (string, error) {
-	query := `select fname from em_people1 where id = $1`
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// PersonPatch holds the fields a patch sets. Nil fields are left as they
+// are; a set field is written even if it is empty.
+type PersonPatch struct {
+	Name        *string
+	Surname     *string
+	Patronymic  *string
+	Age         *int
+	Gender      *string
+	Nationality *string
+}
 
-	var currentName string
-	err := s.db.QueryRowContext(ctx, query, id).Scan(&currentName)
+func (pp PersonPatch) Empty() bool {
+	return pp == PersonPatch{}
+}
 
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return "", fmt.Errorf("person with id %d not found", id)
-		}
-		return "", fmt.Errorf("failed to get person: %w", err)
+// Apply returns p with the patch applied.
+func (pp PersonPatch) Apply(p Person) Person {
+	if pp.Name != nil {
+		p.Name = *pp.Name
+	}
+	if pp.Surname != nil {
+		p.Surname = *pp.Surname
+	}
+	if pp.Patronymic != nil {
+		p.Patronymic = *pp.Patronymic
+	}
+	if pp.Age != nil {
+		p.Age = *pp.Age
+	}
+	if pp.Gender != nil {
+		p.Gender = *pp.Gender
+	}
+	if pp.Nationality != nil {
+		p.Nationality = *pp.Nationality
 	}
-	return currentName, nil
+	return p
 }
 
-func (s *PostgresStorage) UpdatePersonPatch(id int, name, surname, patronymic string, age int, gender, nationality string) error {
+// UpdatePersonPatch writes the fields set in patch. Unless version is
+// AnyVersion, the person must still be at that version.
+func (s *PostgresStorage) UpdatePersonPatch(id int, patch PersonPatch, version int, c Change) (Person, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	var updated Person
+	err := s.withChange(ctx, c, func(tx *sql.Tx) error {
+		var err error
+		updated, err = patchPerson(ctx, tx, id, patch, version)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, ErrPersonNotFound) || errors.Is(err, ErrVersionMismatch) {
+			return Person{}, err
+		}
+		return Person{}, fmt.Errorf("failed to update person: %w", err)
+	}
+
+	return updated, nil
+}
+
+// patchPerson writes the fields set in patch to a live person, if still at
+// version. An empty patch only checks the version.
+func patchPerson(ctx context.Context, tx *sql.Tx, id int, patch PersonPatch, version int) (Person, error) {
 	query := "UPDATE em_people1 SET"
 	args := []interface{}{}
 	argPos := 1
 
-	addField := func(field string, value interface{}, omitEmpty bool) {
-		if !omitEmpty || !isZero(value) {
-			if len(args) > 0 {
-				query += ","
-			}
-			query += fmt.Sprintf(" %s = $%d", field, argPos)
-			args = append(args, value)
-			argPos++
+	addField := func(field string, value interface{}) {
+		if len(args) > 0 {
+			query += ","
 		}
+		query += fmt.Sprintf(" %s = $%d", field, argPos)
+		args = append(args, value)
+		argPos++
 	}
 
-	addField("fname", name, true)
-	addField("surname", surname, true)
-	addField("patronymic", patronymic, true)
-	addField("age", age, true)
-	addField("gender", gender, true)
-	addField("nationality", nationality, true)
+	addName := func(column, raw string) {
+		name := NormalizeName(raw)
+		addField(column, name)
+		addField(column+"_phonetic", phoneticKey(name))
+		addField("raw_"+column, raw)
+	}
 
-	if len(args) == 0 {
-		return nil
+	if patch.Name != nil {
+		addName("fname", *patch.Name)
+	}
+	if patch.Surname != nil {
+		addName("surname", *patch.Surname)
+	}
+	if patch.Patronymic != nil {
+		addName("patronymic", *patch.Patronymic)
+	}
+	if patch.Age != nil {
+		addField("age", *patch.Age)
+	}
+	if patch.Gender != nil {
+		addField("gender", *patch.Gender)
+	}
+	if patch.Nationality != nil {
+		addField("nationality", *patch.Nationality)
 	}
 
-	query += fmt.Sprintf(" WHERE id = $%d", argPos)
-	args = append(args, id)
+	if len(args) == 0 {
+		query = "SELECT " + personColumns + " FROM em_people1"
+	} else {
+		query += ", updated_at = now(), version = version + 1"
+	}
+	query += fmt.Sprintf(" WHERE id = $%d AND %s AND %s",
+		argPos, versionCond(fmt.Sprintf("$%d", argPos+1)), livePeople)
+	if len(args) > 0 {
+		query += " RETURNING " + personColumns
+	}
+	args = append(args, id, version)
 
-	_, err := s.db.ExecContext(ctx, query, args...)
-	if err != nil {
-		return fmt.Errorf("failed to update person: %w", err)
+	p, err := scanPerson(tx.QueryRowContext(ctx, query, args...))
+	if err == sql.ErrNoRows {
+		return Person{}, missedWrite(ctx, tx, id)
 	}
+	return p, err
+}
 
-	return nil
+// versionCond renders the optimistic concurrency check against the version
+// bound at placeholder; AnyVersion passes it.
+func versionCond(placeholder string) string {
+	return fmt.Sprintf("(%[1]s = 0 OR version = %[1]s)", placeholder)
 }
 
-func isZero(value interface{}) bool {
-	switch v := value.(type) {
-	case string:
-		return v == ""
-	case int:
-		return v == 0
-	default:
-		return value == nil
+// missedWrite tells why a conditional write matched no row: the person is
+// gone, or its version moved on.
+func missedWrite(ctx context.Context, tx *sql.Tx, id int) error {
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `select exists(select 1 from em_people1 where id = $1 and `+livePeople+`)`, id).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to get person: %w", err)
+	}
+	if exists {
+		return fmt.Errorf("person with id %d: %w", id, ErrVersionMismatch)
 	}
+	return fmt.Errorf("person with id %d: %w", id, ErrPersonNotFound)
 }
@@ -0,0 +1,145 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type ChangeSource string
+
+const (
+	SourceCreate     ChangeSource = "create"
+	SourcePatch      ChangeSource = "patch"
+	SourceEnrichment ChangeSource = "enrichment"
+	SourceMerge      ChangeSource = "merge"
+	SourceImport     ChangeSource = "import"
+	SourceDelete     ChangeSource = "delete"
+	SourceRestore    ChangeSource = "restore"
+	SourcePurge      ChangeSource = "purge"
+	SourceBulk       ChangeSource = "bulk"
+	SourceNormalize  ChangeSource = "normalize"
+)
+
+// Change says who made a write, how, and within which request. The
+// em_people_audit trigger copies it onto every history row the write
+// produces.
+type Change struct {
+	Actor     string
+	Source    ChangeSource
+	RequestID string
+}
+
+type HistoryEntry struct {
+	ID        int64     `json:"id"`
+	PersonID  int       `json:"person_id"`
+	Field     string    `json:"field" example:"surname"`
+	OldValue  *string   `json:"old_value"`
+	NewValue  *string   `json:"new_value"`
+	Actor     string    `json:"actor"`
+	Source    string    `json:"source" example:"patch"`
+	RequestID string    `json:"request_id"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// setChange makes c visible to the audit trigger for the rest of tx.
+func setChange(ctx context.Context, tx *sql.Tx, c Change) error {
+	_, err := tx.ExecContext(ctx, `select set_config('em.actor', $1, true),
+		set_config('em.source', $2, true), set_config('em.request_id', $3, true)`,
+		c.Actor, string(c.Source), c.RequestID)
+	if err != nil {
+		return fmt.Errorf("failed to set change context: %w", err)
+	}
+	return nil
+}
+
+// withChange runs fn in a transaction attributed to c, so the history rows
+// are committed together with the change itself. Errors from fn are returned
+// as is.
+func (s *PostgresStorage) withChange(ctx context.Context, c Change, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := setChange(ctx, tx, c); err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetPersonHistory returns the recorded changes of a person, oldest first.
+// History outlives the person, so merged, deleted and purged ids still have
+// theirs; only ids that never had any change are reported as not found.
+func (s *PostgresStorage) GetPersonHistory(id, limit, offset int) ([]HistoryEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		select id, person_id, field, old_value, new_value, actor, source, request_id, changed_at
+		from em_people_history
+		where person_id = $1
+		order by changed_at, id
+		limit $2 offset $3`, id, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []HistoryEntry{}
+	for rows.Next() {
+		var e HistoryEntry
+		if err := rows.Scan(&e.ID, &e.PersonID, &e.Field, &e.OldValue, &e.NewValue,
+			&e.Actor, &e.Source, &e.RequestID, &e.ChangedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan history: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get history: %w", err)
+	}
+
+	if len(entries) == 0 && offset == 0 {
+		var exists bool
+		if err := s.db.QueryRowContext(ctx, `select exists(select 1 from em_people1 where id = $1)`, id).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("failed to get person: %w", err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("person with id %d: %w", id, ErrPersonNotFound)
+		}
+	}
+	return entries, nil
+}
+
+// GetPersonAsOf returns the person as it was at the given time, rebuilt from
+// history. As with GetPerson, a person deleted at that time is not found and
+// a merged one yields a MergedError.
+func (s *PostgresStorage) GetPersonAsOf(id int, at time.Time) (Person, error) {
+	query := `SELECT ` + personColumns + `, merged_into FROM em_people_as_of($2) WHERE id = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var mergedInto *int
+	p, err := scanPerson(s.db.QueryRowContext(ctx, query, id, at), &mergedInto)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Person{}, fmt.Errorf("person with id %d as of %s: %w", id, at.Format(time.RFC3339), ErrPersonNotFound)
+		}
+		return Person{}, fmt.Errorf("failed to get person as of %s: %w", at.Format(time.RFC3339), err)
+	}
+	if mergedInto != nil {
+		return Person{}, &MergedError{ID: id, SurvivorID: *mergedInto}
+	}
+	if p.DeletedAt != nil {
+		return Person{}, fmt.Errorf("person with id %d as of %s: %w", id, at.Format(time.RFC3339), ErrPersonNotFound)
+	}
+	return p, nil
+}
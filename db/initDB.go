@@ -53,7 +53,15 @@ func NewPostgresStorage() (*PostgresStorage, error) {
 		return nil, fmt.Errorf("migrations failed: %w", err)
 	}
 
-	return &PostgresStorage{db: db}, nil
+	storage := &PostgresStorage{db: db}
+	if err := storage.BackfillPhoneticKeys(); err != nil {
+		return nil, fmt.Errorf("phonetic backfill failed: %w", err)
+	}
+	if err := storage.BackfillNormalizedNames(); err != nil {
+		return nil, fmt.Errorf("name normalization backfill failed: %w", err)
+	}
+
+	return storage, nil
 }
 
 func Init(db *sql.DB, dbName, userName, userPassword string) error {
@@ -0,0 +1,302 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrInvalidMerge marks merge requests that are rejected before any row is
+	// touched. Handlers map it to 400.
+	ErrInvalidMerge = errors.New("invalid merge")
+	// ErrPersonMerged is matched by MergedError.
+	ErrPersonMerged = errors.New("person was merged")
+	// ErrMergeConflict means the records changed between re-enrichment and
+	// the merge transaction; the merge can simply be retried.
+	ErrMergeConflict = errors.New("people changed during merge")
+)
+
+// MergedError is returned for ids that were merged into another record and
+// only remain as tombstones.
+type MergedError struct {
+	ID         int
+	SurvivorID int
+}
+
+func (e *MergedError) Error() string {
+	return fmt.Sprintf("person with id %d was merged into %d", e.ID, e.SurvivorID)
+}
+
+func (e *MergedError) Is(target error) bool {
+	return target == ErrPersonMerged
+}
+
+type MergeChoice string
+
+const (
+	MergeKeepSurvivor MergeChoice = "survivor"
+	MergeTakeVictim   MergeChoice = "victim"
+	MergeReEnrich     MergeChoice = "re_enrich"
+)
+
+// MergeField says where a field of the merged record comes from. From is the
+// victim id for MergeTakeVictim and may be left out when there is only one
+// victim.
+type MergeField struct {
+	Choice MergeChoice `json:"choice" enums:"survivor,victim,re_enrich"`
+	From   int         `json:"from,omitempty"`
+}
+
+// mergeFields are the fields a merge can choose between. Only enriched fields
+// can be re-enriched.
+var mergeFields = map[string]bool{
+	"name":        false,
+	"surname":     false,
+	"patronymic":  false,
+	"age":         true,
+	"gender":      true,
+	"nationality": true,
+}
+
+type MergeRequest struct {
+	SurvivorID int
+	VictimIDs  []int
+	Fields     map[string]MergeField
+	// Enriched carries fresh age, gender and nationality for the re_enrich
+	// fields, fetched for the merged name before the transaction starts.
+	Enriched *Person
+}
+
+// NeedsEnrichment reports whether any field asks for re-enrichment.
+func (m MergeRequest) NeedsEnrichment() bool {
+	for _, f := range m.Fields {
+		if f.Choice == MergeReEnrich {
+			return true
+		}
+	}
+	return false
+}
+
+func (m MergeRequest) validate() error {
+	if len(m.VictimIDs) == 0 {
+		return fmt.Errorf("%w: at least one victim id is required", ErrInvalidMerge)
+	}
+	seen := map[int]bool{m.SurvivorID: true}
+	for _, id := range m.VictimIDs {
+		if seen[id] {
+			return fmt.Errorf("%w: id %d is listed more than once", ErrInvalidMerge, id)
+		}
+		seen[id] = true
+	}
+	for field, f := range m.Fields {
+		enrichable, ok := mergeFields[field]
+		if !ok {
+			return fmt.Errorf("%w: unknown field %q", ErrInvalidMerge, field)
+		}
+		switch f.Choice {
+		case MergeKeepSurvivor:
+		case MergeTakeVictim:
+			if f.From == 0 && len(m.VictimIDs) > 1 {
+				return fmt.Errorf("%w: %s: from is required with several victims", ErrInvalidMerge, field)
+			}
+			if f.From != 0 && (f.From == m.SurvivorID || !seen[f.From]) {
+				return fmt.Errorf("%w: %s: %d is not one of the victims", ErrInvalidMerge, field, f.From)
+			}
+		case MergeReEnrich:
+			if !enrichable {
+				return fmt.Errorf("%w: %s cannot be re-enriched", ErrInvalidMerge, field)
+			}
+		default:
+			return fmt.Errorf("%w: %s: choice must be survivor, victim or re_enrich", ErrInvalidMerge, field)
+		}
+	}
+	return nil
+}
+
+// ResolveMerge builds the merged record from the survivor and victims.
+// Fields to be re-enriched keep the survivor's value; MergePeople fills them
+// from MergeRequest.Enriched.
+func ResolveMerge(survivor Person, victims []Person, req MergeRequest) (Person, error) {
+	if err := req.validate(); err != nil {
+		return Person{}, err
+	}
+	byID := make(map[int]Person, len(victims))
+	for _, v := range victims {
+		byID[v.ID] = v
+	}
+
+	merged := survivor
+	for field, f := range req.Fields {
+		if f.Choice != MergeTakeVictim {
+			continue
+		}
+		from := f.From
+		if from == 0 {
+			from = req.VictimIDs[0]
+		}
+		v, ok := byID[from]
+		if !ok {
+			return Person{}, fmt.Errorf("person with id %d: %w", from, ErrPersonNotFound)
+		}
+		switch field {
+		case "name":
+			merged.Name = v.Name
+		case "surname":
+			merged.Surname = v.Surname
+		case "patronymic":
+			merged.Patronymic = v.Patronymic
+		case "age":
+			merged.Age = v.Age
+		case "gender":
+			merged.Gender, merged.GenderProbability = v.Gender, v.GenderProbability
+		case "nationality":
+			merged.Nationality, merged.NationalityProbability = v.Nationality, v.NationalityProbability
+		}
+	}
+	return merged, nil
+}
+
+// MergePeople folds the victims into the survivor in one transaction. All
+// rows are locked, the merged fields are written to the survivor, victims
+// (and anything already merged into them) become tombstones pointing at the
+// survivor, and one em_people_merges row per victim records the choices and
+// the victim as it was.
+func (s *PostgresStorage) MergePeople(req MergeRequest, c Change) (Person, error) {
+	if err := req.validate(); err != nil {
+		return Person{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Person{}, fmt.Errorf("failed to begin merge: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := setChange(ctx, tx, c); err != nil {
+		return Person{}, err
+	}
+
+	ids := append([]int{req.SurvivorID}, req.VictimIDs...)
+	rows, err := tx.QueryContext(ctx, `SELECT `+personColumns+`, merged_into FROM em_people1
+		WHERE id = ANY($1) ORDER BY id FOR UPDATE`, pq.Array(ids))
+	if err != nil {
+		return Person{}, fmt.Errorf("failed to lock people for merge: %w", err)
+	}
+	locked := make(map[int]Person, len(ids))
+	for rows.Next() {
+		var mergedInto *int
+		p, err := scanPerson(rows, &mergedInto)
+		if err != nil {
+			rows.Close()
+			return Person{}, fmt.Errorf("failed to scan person for merge: %w", err)
+		}
+		if mergedInto != nil {
+			rows.Close()
+			return Person{}, &MergedError{ID: p.ID, SurvivorID: *mergedInto}
+		}
+		if p.DeletedAt != nil {
+			// Left out of locked, so it is reported as not found below.
+			continue
+		}
+		locked[p.ID] = p
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return Person{}, fmt.Errorf("failed to lock people for merge: %w", err)
+	}
+
+	victims := make([]Person, 0, len(req.VictimIDs))
+	for _, id := range ids {
+		p, ok := locked[id]
+		if !ok {
+			return Person{}, fmt.Errorf("person with id %d: %w", id, ErrPersonNotFound)
+		}
+		if id != req.SurvivorID {
+			victims = append(victims, p)
+		}
+	}
+
+	merged, err := ResolveMerge(locked[req.SurvivorID], victims, req)
+	if err != nil {
+		return Person{}, err
+	}
+	reEnriched := req.NeedsEnrichment()
+	if reEnriched {
+		if req.Enriched == nil || req.Enriched.Name != merged.Name {
+			return Person{}, ErrMergeConflict
+		}
+		for field, f := range req.Fields {
+			if f.Choice != MergeReEnrich {
+				continue
+			}
+			switch field {
+			case "age":
+				merged.Age = req.Enriched.Age
+			case "gender":
+				merged.Gender, merged.GenderProbability = req.Enriched.Gender, req.Enriched.GenderProbability
+			case "nationality":
+				merged.Nationality, merged.NationalityProbability = req.Enriched.Nationality, req.Enriched.NationalityProbability
+			}
+		}
+	}
+
+	survivor, err := scanPerson(tx.QueryRowContext(ctx, `
+		update em_people1 set
+			fname = $1, surname = $2, patronymic = $3, age = $4, gender = $5, nationality = $6,
+			gender_probability = $7, nationality_probability = $8,
+			fname_phonetic = $9, surname_phonetic = $10, patronymic_phonetic = $11,
+			raw_fname = case when fname = $1 then raw_fname else $1 end,
+			raw_surname = case when surname = $2 then raw_surname else $2 end,
+			raw_patronymic = case when patronymic = $3 then raw_patronymic else $3 end,
+			enriched_at = case when $12 then now() else enriched_at end,
+			updated_at = now(),
+			version = version + 1
+		where id = $13
+		returning `+personColumns,
+		merged.Name, merged.Surname, merged.Patronymic, merged.Age, merged.Gender, merged.Nationality,
+		merged.GenderProbability, merged.NationalityProbability,
+		phoneticKey(merged.Name), phoneticKey(merged.Surname), phoneticKey(merged.Patronymic),
+		reEnriched, req.SurvivorID))
+	if err != nil {
+		return Person{}, fmt.Errorf("failed to update survivor: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		update em_people1 set merged_into = $1, updated_at = now(), version = version + 1
+		where id = ANY($2) or merged_into = ANY($2)`,
+		req.SurvivorID, pq.Array(req.VictimIDs)); err != nil {
+		return Person{}, fmt.Errorf("failed to mark merged people: %w", err)
+	}
+
+	if req.Fields == nil {
+		req.Fields = map[string]MergeField{}
+	}
+	fields, err := json.Marshal(req.Fields)
+	if err != nil {
+		return Person{}, fmt.Errorf("failed to encode merge fields: %w", err)
+	}
+	for _, v := range victims {
+		snapshot, err := json.Marshal(v)
+		if err != nil {
+			return Person{}, fmt.Errorf("failed to encode merged person: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `
+			insert into em_people_merges (survivor_id, victim_id, fields, victim)
+			values ($1, $2, $3, $4)`, req.SurvivorID, v.ID, fields, snapshot); err != nil {
+			return Person{}, fmt.Errorf("failed to record merge: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Person{}, fmt.Errorf("failed to commit merge: %w", err)
+	}
+	return survivor, nil
+}
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE em_people1
+    ADD COLUMN IF NOT EXISTS gender_probability real NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS nationality_probability real NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS enriched_at timestamptz,
+    ADD COLUMN IF NOT EXISTS updated_at timestamptz NOT NULL DEFAULT now();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE em_people1
+    DROP COLUMN IF EXISTS gender_probability,
+    DROP COLUMN IF EXISTS nationality_probability,
+    DROP COLUMN IF EXISTS enriched_at,
+    DROP COLUMN IF EXISTS updated_at;
+-- +goose StatementEnd
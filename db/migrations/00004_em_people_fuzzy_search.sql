@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE em_people1
+    ADD COLUMN IF NOT EXISTS fname_phonetic varchar(100) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS surname_phonetic varchar(200) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS patronymic_phonetic varchar(200) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS em_people1_fname_trgm_idx ON em_people1 USING gin (fname gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS em_people1_surname_trgm_idx ON em_people1 USING gin (surname gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS em_people1_patronymic_trgm_idx ON em_people1 USING gin (patronymic gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS em_people1_full_name_trgm_idx ON em_people1
+    USING gin ((fname || ' ' || surname || ' ' || patronymic) gin_trgm_ops);
+
+CREATE INDEX IF NOT EXISTS em_people1_fname_phonetic_idx ON em_people1 (fname_phonetic);
+CREATE INDEX IF NOT EXISTS em_people1_surname_phonetic_idx ON em_people1 (surname_phonetic);
+CREATE INDEX IF NOT EXISTS em_people1_patronymic_phonetic_idx ON em_people1 (patronymic_phonetic);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS em_people1_patronymic_phonetic_idx;
+DROP INDEX IF EXISTS em_people1_surname_phonetic_idx;
+DROP INDEX IF EXISTS em_people1_fname_phonetic_idx;
+DROP INDEX IF EXISTS em_people1_full_name_trgm_idx;
+DROP INDEX IF EXISTS em_people1_patronymic_trgm_idx;
+DROP INDEX IF EXISTS em_people1_surname_trgm_idx;
+DROP INDEX IF EXISTS em_people1_fname_trgm_idx;
+
+ALTER TABLE em_people1
+    DROP COLUMN IF EXISTS fname_phonetic,
+    DROP COLUMN IF EXISTS surname_phonetic,
+    DROP COLUMN IF EXISTS patronymic_phonetic;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE em_people1
+    ADD COLUMN IF NOT EXISTS search_tsv tsvector GENERATED ALWAYS AS (
+        to_tsvector('russian', fname || ' ' || surname || ' ' || patronymic) ||
+        to_tsvector('simple', fname || ' ' || surname || ' ' || patronymic)
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS em_people1_search_tsv_idx ON em_people1 USING gin (search_tsv);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS em_people1_search_tsv_idx;
+ALTER TABLE em_people1 DROP COLUMN IF EXISTS search_tsv;
+-- +goose StatementEnd
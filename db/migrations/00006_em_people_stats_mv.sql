@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE MATERIALIZED VIEW IF NOT EXISTS em_people_stats_mv AS
+SELECT nationality, gender, age, count(*) AS n, now() AS refreshed_at
+FROM em_people1
+GROUP BY nationality, gender, age;
+
+CREATE UNIQUE INDEX IF NOT EXISTS em_people_stats_mv_key_idx
+    ON em_people_stats_mv (nationality, gender, age);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP MATERIALIZED VIEW IF EXISTS em_people_stats_mv;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION em_name_key(fname text, surname text, patronymic text)
+RETURNS text
+LANGUAGE sql IMMUTABLE PARALLEL SAFE
+AS $$
+    SELECT lower(translate(
+        regexp_replace(btrim(fname) || ' ' || btrim(surname) || ' ' || btrim(patronymic), '\s+', ' ', 'g'),
+        'Ёё', 'Ее'))
+$$;
+
+ALTER TABLE em_people1
+    ADD COLUMN IF NOT EXISTS name_key text
+    GENERATED ALWAYS AS (em_name_key(fname, surname, patronymic)) STORED;
+
+CREATE INDEX IF NOT EXISTS em_people1_name_key_idx ON em_people1 (name_key);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS em_people1_name_key_idx;
+ALTER TABLE em_people1 DROP COLUMN IF EXISTS name_key;
+DROP FUNCTION IF EXISTS em_name_key(text, text, text);
+-- +goose StatementEnd
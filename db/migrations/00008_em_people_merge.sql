@@ -0,0 +1,48 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE em_people1
+    ADD COLUMN IF NOT EXISTS merged_into INT REFERENCES em_people1 (id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS em_people1_merged_into_idx
+    ON em_people1 (merged_into) WHERE merged_into IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS em_people_merges (
+    id SERIAL PRIMARY KEY,
+    survivor_id INT NOT NULL REFERENCES em_people1 (id) ON DELETE CASCADE,
+    victim_id INT NOT NULL,
+    fields JSONB NOT NULL,
+    victim JSONB NOT NULL,
+    merged_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS em_people_merges_survivor_idx ON em_people_merges (survivor_id);
+
+-- Tombstones must not be counted, so the stats view is rebuilt with a filter.
+DROP MATERIALIZED VIEW IF EXISTS em_people_stats_mv;
+
+CREATE MATERIALIZED VIEW em_people_stats_mv AS
+SELECT nationality, gender, age, count(*) AS n, now() AS refreshed_at
+FROM em_people1
+WHERE merged_into IS NULL
+GROUP BY nationality, gender, age;
+
+CREATE UNIQUE INDEX IF NOT EXISTS em_people_stats_mv_key_idx
+    ON em_people_stats_mv (nationality, gender, age);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP MATERIALIZED VIEW IF EXISTS em_people_stats_mv;
+
+CREATE MATERIALIZED VIEW em_people_stats_mv AS
+SELECT nationality, gender, age, count(*) AS n, now() AS refreshed_at
+FROM em_people1
+GROUP BY nationality, gender, age;
+
+CREATE UNIQUE INDEX IF NOT EXISTS em_people_stats_mv_key_idx
+    ON em_people_stats_mv (nationality, gender, age);
+
+DROP TABLE IF EXISTS em_people_merges;
+DROP INDEX IF EXISTS em_people1_merged_into_idx;
+ALTER TABLE em_people1 DROP COLUMN IF EXISTS merged_into;
+-- +goose StatementEnd
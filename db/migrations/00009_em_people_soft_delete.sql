@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE em_people1 ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS em_people1_deleted_at_idx
+    ON em_people1 (deleted_at) WHERE deleted_at IS NOT NULL;
+
+DROP MATERIALIZED VIEW IF EXISTS em_people_stats_mv;
+
+CREATE MATERIALIZED VIEW em_people_stats_mv AS
+SELECT nationality, gender, age, count(*) AS n, now() AS refreshed_at
+FROM em_people1
+WHERE merged_into IS NULL AND deleted_at IS NULL
+GROUP BY nationality, gender, age;
+
+CREATE UNIQUE INDEX IF NOT EXISTS em_people_stats_mv_key_idx
+    ON em_people_stats_mv (nationality, gender, age);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP MATERIALIZED VIEW IF EXISTS em_people_stats_mv;
+
+CREATE MATERIALIZED VIEW em_people_stats_mv AS
+SELECT nationality, gender, age, count(*) AS n, now() AS refreshed_at
+FROM em_people1
+WHERE merged_into IS NULL
+GROUP BY nationality, gender, age;
+
+CREATE UNIQUE INDEX IF NOT EXISTS em_people_stats_mv_key_idx
+    ON em_people_stats_mv (nationality, gender, age);
+
+DROP INDEX IF EXISTS em_people1_deleted_at_idx;
+ALTER TABLE em_people1 DROP COLUMN IF EXISTS deleted_at;
+-- +goose StatementEnd
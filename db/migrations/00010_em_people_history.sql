@@ -0,0 +1,80 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS em_people_history (
+    id BIGSERIAL PRIMARY KEY,
+    person_id INT NOT NULL,
+    field TEXT NOT NULL,
+    old_value TEXT,
+    new_value TEXT,
+    actor TEXT NOT NULL DEFAULT '',
+    source TEXT NOT NULL,
+    request_id TEXT NOT NULL DEFAULT '',
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS em_people_history_person_idx
+    ON em_people_history (person_id, changed_at, id);
+
+-- History is append-only.
+CREATE OR REPLACE FUNCTION em_people_history_readonly() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    RAISE EXCEPTION 'em_people_history is append-only';
+END
+$$;
+
+CREATE TRIGGER em_people_history_readonly
+    BEFORE UPDATE OR DELETE ON em_people_history
+    FOR EACH STATEMENT EXECUTE FUNCTION em_people_history_readonly();
+
+-- em_people_audit writes one history row per changed field. Actor, source and
+-- request id come from transaction-local settings set by the application;
+-- without them the source falls back to the statement type.
+CREATE OR REPLACE FUNCTION em_people_audit() RETURNS trigger
+LANGUAGE plpgsql AS $$
+DECLARE
+    old_row jsonb := '{}';
+    new_row jsonb := '{}';
+    person_id int;
+    col text;
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        old_row := to_jsonb(OLD);
+        person_id := OLD.id;
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        new_row := to_jsonb(NEW);
+        person_id := NEW.id;
+    END IF;
+
+    FOREACH col IN ARRAY ARRAY['fname', 'surname', 'patronymic', 'age', 'gender', 'nationality',
+        'gender_probability', 'nationality_probability', 'enriched_at', 'deleted_at', 'merged_into']
+    LOOP
+        IF old_row ->> col IS DISTINCT FROM new_row ->> col THEN
+            INSERT INTO em_people_history (person_id, field, old_value, new_value, actor, source, request_id)
+            VALUES (
+                person_id,
+                CASE col WHEN 'fname' THEN 'name' ELSE col END,
+                old_row ->> col,
+                new_row ->> col,
+                coalesce(current_setting('em.actor', true), ''),
+                coalesce(nullif(current_setting('em.source', true), ''), lower(TG_OP)),
+                coalesce(current_setting('em.request_id', true), ''));
+        END IF;
+    END LOOP;
+    RETURN NULL;
+END
+$$;
+
+CREATE TRIGGER em_people_audit
+    AFTER INSERT OR UPDATE OR DELETE ON em_people1
+    FOR EACH ROW EXECUTE FUNCTION em_people_audit();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS em_people_audit ON em_people1;
+DROP FUNCTION IF EXISTS em_people_audit();
+DROP TABLE IF EXISTS em_people_history;
+DROP FUNCTION IF EXISTS em_people_history_readonly();
+-- +goose StatementEnd
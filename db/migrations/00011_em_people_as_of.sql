@@ -0,0 +1,99 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Rows written before em_people_history existed have no history, or only the
+-- changes made since. Their earlier state is recorded as one 'baseline' entry
+-- per field: the old value of the first recorded change of that field, or the
+-- current value if it never changed. Baselines are dated at updated_at, or
+-- just before the first recorded change.
+WITH first_change AS (
+    SELECT person_id, min(changed_at) AS changed_at
+    FROM em_people_history
+    GROUP BY person_id
+), inserted AS (
+    SELECT DISTINCT person_id
+    FROM em_people_history
+    WHERE field = 'name' AND old_value IS NULL
+), baseline AS (
+    SELECT p.id AS person_id,
+        CASE col WHEN 'fname' THEN 'name' ELSE col END AS field,
+        CASE WHEN fe.person_id IS NULL THEN to_jsonb(p) ->> col ELSE fe.old_value END AS value,
+        CASE WHEN fc.changed_at IS NULL THEN p.updated_at
+             ELSE fc.changed_at - interval '1 microsecond' END AS changed_at
+    FROM em_people1 p
+    CROSS JOIN unnest(ARRAY['fname', 'surname', 'patronymic', 'age', 'gender', 'nationality',
+        'gender_probability', 'nationality_probability', 'enriched_at', 'deleted_at', 'merged_into']) AS col
+    LEFT JOIN first_change fc ON fc.person_id = p.id
+    LEFT JOIN LATERAL (
+        SELECT h.person_id, h.old_value
+        FROM em_people_history h
+        WHERE h.person_id = p.id AND h.field = CASE col WHEN 'fname' THEN 'name' ELSE col END
+        ORDER BY h.changed_at, h.id
+        LIMIT 1
+    ) fe ON true
+    WHERE p.id NOT IN (SELECT person_id FROM inserted)
+)
+INSERT INTO em_people_history (person_id, field, old_value, new_value, source, changed_at)
+SELECT person_id, field, NULL, value, 'baseline', changed_at
+FROM baseline
+WHERE value IS NOT NULL;
+
+-- em_people_as_of rebuilds em_people1 as it was at ts from the latest history
+-- entry of every field. A person exists at ts if it had a name then; purges
+-- clear every field.
+CREATE OR REPLACE FUNCTION em_people_as_of(ts timestamptz)
+RETURNS TABLE (
+    id int,
+    fname text,
+    surname text,
+    patronymic text,
+    age int,
+    nationality text,
+    gender text,
+    gender_probability real,
+    nationality_probability real,
+    enriched_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    merged_into int
+)
+LANGUAGE sql STABLE
+AS $$
+    WITH latest AS (
+        SELECT DISTINCT ON (h.person_id, h.field) h.person_id, h.field, h.new_value, h.changed_at
+        FROM em_people_history h
+        WHERE h.changed_at <= ts
+        ORDER BY h.person_id, h.field, h.changed_at DESC, h.id DESC
+    ), state AS (
+        SELECT person_id,
+            max(new_value) FILTER (WHERE field = 'name') AS fname,
+            max(new_value) FILTER (WHERE field = 'surname') AS surname,
+            max(new_value) FILTER (WHERE field = 'patronymic') AS patronymic,
+            max(new_value) FILTER (WHERE field = 'age') AS age,
+            max(new_value) FILTER (WHERE field = 'nationality') AS nationality,
+            max(new_value) FILTER (WHERE field = 'gender') AS gender,
+            max(new_value) FILTER (WHERE field = 'gender_probability') AS gender_probability,
+            max(new_value) FILTER (WHERE field = 'nationality_probability') AS nationality_probability,
+            max(new_value) FILTER (WHERE field = 'enriched_at') AS enriched_at,
+            max(new_value) FILTER (WHERE field = 'deleted_at') AS deleted_at,
+            max(new_value) FILTER (WHERE field = 'merged_into') AS merged_into,
+            max(changed_at) AS updated_at
+        FROM latest
+        GROUP BY person_id
+    )
+    SELECT person_id, fname, coalesce(surname, ''), coalesce(patronymic, ''),
+        coalesce(age::int, 0), coalesce(nationality, ''), coalesce(gender, ''),
+        coalesce(gender_probability::real, 0), coalesce(nationality_probability::real, 0),
+        enriched_at::timestamptz, updated_at, deleted_at::timestamptz, merged_into::int
+    FROM state
+    WHERE fname IS NOT NULL
+$$;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP FUNCTION IF EXISTS em_people_as_of(timestamptz);
+
+ALTER TABLE em_people_history DISABLE TRIGGER em_people_history_readonly;
+DELETE FROM em_people_history WHERE source = 'baseline';
+ALTER TABLE em_people_history ENABLE TRIGGER em_people_history_readonly;
+-- +goose StatementEnd
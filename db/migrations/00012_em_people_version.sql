@@ -0,0 +1,111 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE em_people1 ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
+
+-- Snapshots rebuilt from history have no version; they report 0.
+DROP FUNCTION IF EXISTS em_people_as_of(timestamptz);
+
+CREATE FUNCTION em_people_as_of(ts timestamptz)
+RETURNS TABLE (
+    id int,
+    fname text,
+    surname text,
+    patronymic text,
+    age int,
+    nationality text,
+    gender text,
+    gender_probability real,
+    nationality_probability real,
+    enriched_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    merged_into int,
+    version int
+)
+LANGUAGE sql STABLE
+AS $$
+    WITH latest AS (
+        SELECT DISTINCT ON (h.person_id, h.field) h.person_id, h.field, h.new_value, h.changed_at
+        FROM em_people_history h
+        WHERE h.changed_at <= ts
+        ORDER BY h.person_id, h.field, h.changed_at DESC, h.id DESC
+    ), state AS (
+        SELECT person_id,
+            max(new_value) FILTER (WHERE field = 'name') AS fname,
+            max(new_value) FILTER (WHERE field = 'surname') AS surname,
+            max(new_value) FILTER (WHERE field = 'patronymic') AS patronymic,
+            max(new_value) FILTER (WHERE field = 'age') AS age,
+            max(new_value) FILTER (WHERE field = 'nationality') AS nationality,
+            max(new_value) FILTER (WHERE field = 'gender') AS gender,
+            max(new_value) FILTER (WHERE field = 'gender_probability') AS gender_probability,
+            max(new_value) FILTER (WHERE field = 'nationality_probability') AS nationality_probability,
+            max(new_value) FILTER (WHERE field = 'enriched_at') AS enriched_at,
+            max(new_value) FILTER (WHERE field = 'deleted_at') AS deleted_at,
+            max(new_value) FILTER (WHERE field = 'merged_into') AS merged_into,
+            max(changed_at) AS updated_at
+        FROM latest
+        GROUP BY person_id
+    )
+    SELECT person_id, fname, coalesce(surname, ''), coalesce(patronymic, ''),
+        coalesce(age::int, 0), coalesce(nationality, ''), coalesce(gender, ''),
+        coalesce(gender_probability::real, 0), coalesce(nationality_probability::real, 0),
+        enriched_at::timestamptz, updated_at, deleted_at::timestamptz, merged_into::int, 0
+    FROM state
+    WHERE fname IS NOT NULL
+$$;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP FUNCTION IF EXISTS em_people_as_of(timestamptz);
+
+CREATE FUNCTION em_people_as_of(ts timestamptz)
+RETURNS TABLE (
+    id int,
+    fname text,
+    surname text,
+    patronymic text,
+    age int,
+    nationality text,
+    gender text,
+    gender_probability real,
+    nationality_probability real,
+    enriched_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    merged_into int
+)
+LANGUAGE sql STABLE
+AS $$
+    WITH latest AS (
+        SELECT DISTINCT ON (h.person_id, h.field) h.person_id, h.field, h.new_value, h.changed_at
+        FROM em_people_history h
+        WHERE h.changed_at <= ts
+        ORDER BY h.person_id, h.field, h.changed_at DESC, h.id DESC
+    ), state AS (
+        SELECT person_id,
+            max(new_value) FILTER (WHERE field = 'name') AS fname,
+            max(new_value) FILTER (WHERE field = 'surname') AS surname,
+            max(new_value) FILTER (WHERE field = 'patronymic') AS patronymic,
+            max(new_value) FILTER (WHERE field = 'age') AS age,
+            max(new_value) FILTER (WHERE field = 'nationality') AS nationality,
+            max(new_value) FILTER (WHERE field = 'gender') AS gender,
+            max(new_value) FILTER (WHERE field = 'gender_probability') AS gender_probability,
+            max(new_value) FILTER (WHERE field = 'nationality_probability') AS nationality_probability,
+            max(new_value) FILTER (WHERE field = 'enriched_at') AS enriched_at,
+            max(new_value) FILTER (WHERE field = 'deleted_at') AS deleted_at,
+            max(new_value) FILTER (WHERE field = 'merged_into') AS merged_into,
+            max(changed_at) AS updated_at
+        FROM latest
+        GROUP BY person_id
+    )
+    SELECT person_id, fname, coalesce(surname, ''), coalesce(patronymic, ''),
+        coalesce(age::int, 0), coalesce(nationality, ''), coalesce(gender, ''),
+        coalesce(gender_probability::real, 0), coalesce(nationality_probability::real, 0),
+        enriched_at::timestamptz, updated_at, deleted_at::timestamptz, merged_into::int
+    FROM state
+    WHERE fname IS NOT NULL
+$$;
+
+ALTER TABLE em_people1 DROP COLUMN IF EXISTS version;
+-- +goose StatementEnd
@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Names are stored normalized; raw_* keep them as they were sent. NULL means
+-- the row predates normalization and is picked up by the startup backfill.
+ALTER TABLE em_people1
+    ADD COLUMN IF NOT EXISTS raw_fname text,
+    ADD COLUMN IF NOT EXISTS raw_surname text,
+    ADD COLUMN IF NOT EXISTS raw_patronymic text;
+
+-- em_lookup_key is the SQL side of db.NameLookupKey for a stored,
+-- already normalized name.
+CREATE OR REPLACE FUNCTION em_lookup_key(name text)
+RETURNS text
+LANGUAGE sql IMMUTABLE PARALLEL SAFE
+AS $$
+    SELECT lower(translate(name, 'Ёё', 'Ее'))
+$$;
+
+CREATE INDEX IF NOT EXISTS em_people1_fname_lookup_idx ON em_people1 (em_lookup_key(fname));
+CREATE INDEX IF NOT EXISTS em_people1_surname_lookup_idx ON em_people1 (em_lookup_key(surname));
+CREATE INDEX IF NOT EXISTS em_people1_patronymic_lookup_idx ON em_people1 (em_lookup_key(patronymic));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS em_people1_patronymic_lookup_idx;
+DROP INDEX IF EXISTS em_people1_surname_lookup_idx;
+DROP INDEX IF EXISTS em_people1_fname_lookup_idx;
+DROP FUNCTION IF EXISTS em_lookup_key(text);
+
+ALTER TABLE em_people1
+    DROP COLUMN IF EXISTS raw_fname,
+    DROP COLUMN IF EXISTS raw_surname,
+    DROP COLUMN IF EXISTS raw_patronymic;
+-- +goose StatementEnd
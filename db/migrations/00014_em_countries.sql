@@ -0,0 +1,284 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS em_countries (
+    alpha2 varchar(2) PRIMARY KEY,
+    alpha3 varchar(3) NOT NULL UNIQUE,
+    numeric_code varchar(3) NOT NULL UNIQUE,
+    name_en text NOT NULL,
+    name_ru text NOT NULL
+);
+
+INSERT INTO em_countries (alpha2, alpha3, numeric_code, name_en, name_ru) VALUES
+    ('AD', 'AND', '020', 'Andorra', 'Андорра'),
+    ('AE', 'ARE', '784', 'United Arab Emirates', 'ОАЭ'),
+    ('AF', 'AFG', '004', 'Afghanistan', 'Афганистан'),
+    ('AG', 'ATG', '028', 'Antigua and Barbuda', 'Антигуа и Барбуда'),
+    ('AI', 'AIA', '660', 'Anguilla', 'Ангилья'),
+    ('AL', 'ALB', '008', 'Albania', 'Албания'),
+    ('AM', 'ARM', '051', 'Armenia', 'Армения'),
+    ('AO', 'AGO', '024', 'Angola', 'Ангола'),
+    ('AQ', 'ATA', '010', 'Antarctica', 'Антарктида'),
+    ('AR', 'ARG', '032', 'Argentina', 'Аргентина'),
+    ('AS', 'ASM', '016', 'American Samoa', 'Американское Самоа'),
+    ('AT', 'AUT', '040', 'Austria', 'Австрия'),
+    ('AU', 'AUS', '036', 'Australia', 'Австралия'),
+    ('AW', 'ABW', '533', 'Aruba', 'Аруба'),
+    ('AX', 'ALA', '248', 'Åland Islands', 'Аландские острова'),
+    ('AZ', 'AZE', '031', 'Azerbaijan', 'Азербайджан'),
+    ('BA', 'BIH', '070', 'Bosnia and Herzegovina', 'Босния и Герцеговина'),
+    ('BB', 'BRB', '052', 'Barbados', 'Барбадос'),
+    ('BD', 'BGD', '050', 'Bangladesh', 'Бангладеш'),
+    ('BE', 'BEL', '056', 'Belgium', 'Бельгия'),
+    ('BF', 'BFA', '854', 'Burkina Faso', 'Буркина-Фасо'),
+    ('BG', 'BGR', '100', 'Bulgaria', 'Болгария'),
+    ('BH', 'BHR', '048', 'Bahrain', 'Бахрейн'),
+    ('BI', 'BDI', '108', 'Burundi', 'Бурунди'),
+    ('BJ', 'BEN', '204', 'Benin', 'Бенин'),
+    ('BL', 'BLM', '652', 'Saint Barthélemy', 'Сен-Бартелеми'),
+    ('BM', 'BMU', '060', 'Bermuda', 'Бермудские острова'),
+    ('BN', 'BRN', '096', 'Brunei', 'Бруней-Даруссалам'),
+    ('BO', 'BOL', '068', 'Bolivia', 'Боливия'),
+    ('BQ', 'BES', '535', 'Caribbean Netherlands', 'Бонэйр, Синт-Эстатиус и Саба'),
+    ('BR', 'BRA', '076', 'Brazil', 'Бразилия'),
+    ('BS', 'BHS', '044', 'Bahamas', 'Багамы'),
+    ('BT', 'BTN', '064', 'Bhutan', 'Бутан'),
+    ('BV', 'BVT', '074', 'Bouvet Island', 'Остров Буве'),
+    ('BW', 'BWA', '072', 'Botswana', 'Ботсвана'),
+    ('BY', 'BLR', '112', 'Belarus', 'Беларусь'),
+    ('BZ', 'BLZ', '084', 'Belize', 'Белиз'),
+    ('CA', 'CAN', '124', 'Canada', 'Канада'),
+    ('CC', 'CCK', '166', 'Cocos (Keeling) Islands', 'Кокосовые острова'),
+    ('CD', 'COD', '180', 'Democratic Republic of the Congo', 'Демократическая Республика Конго'),
+    ('CF', 'CAF', '140', 'Central African Republic', 'Центрально-Африканская Республика'),
+    ('CG', 'COG', '178', 'Congo', 'Республика Конго'),
+    ('CH', 'CHE', '756', 'Switzerland', 'Швейцария'),
+    ('CI', 'CIV', '384', 'Côte d’Ivoire', 'Кот-д’Ивуар'),
+    ('CK', 'COK', '184', 'Cook Islands', 'Острова Кука'),
+    ('CL', 'CHL', '152', 'Chile', 'Чили'),
+    ('CM', 'CMR', '120', 'Cameroon', 'Камерун'),
+    ('CN', 'CHN', '156', 'China', 'Китай'),
+    ('CO', 'COL', '170', 'Colombia', 'Колумбия'),
+    ('CR', 'CRI', '188', 'Costa Rica', 'Коста-Рика'),
+    ('CU', 'CUB', '192', 'Cuba', 'Куба'),
+    ('CV', 'CPV', '132', 'Cabo Verde', 'Кабо-Верде'),
+    ('CW', 'CUW', '531', 'Curaçao', 'Кюрасао'),
+    ('CX', 'CXR', '162', 'Christmas Island', 'Остров Рождества'),
+    ('CY', 'CYP', '196', 'Cyprus', 'Кипр'),
+    ('CZ', 'CZE', '203', 'Czechia', 'Чехия'),
+    ('DE', 'DEU', '276', 'Germany', 'Германия'),
+    ('DJ', 'DJI', '262', 'Djibouti', 'Джибути'),
+    ('DK', 'DNK', '208', 'Denmark', 'Дания'),
+    ('DM', 'DMA', '212', 'Dominica', 'Доминика'),
+    ('DO', 'DOM', '214', 'Dominican Republic', 'Доминиканская Республика'),
+    ('DZ', 'DZA', '012', 'Algeria', 'Алжир'),
+    ('EC', 'ECU', '218', 'Ecuador', 'Эквадор'),
+    ('EE', 'EST', '233', 'Estonia', 'Эстония'),
+    ('EG', 'EGY', '818', 'Egypt', 'Египет'),
+    ('EH', 'ESH', '732', 'Western Sahara', 'Западная Сахара'),
+    ('ER', 'ERI', '232', 'Eritrea', 'Эритрея'),
+    ('ES', 'ESP', '724', 'Spain', 'Испания'),
+    ('ET', 'ETH', '231', 'Ethiopia', 'Эфиопия'),
+    ('FI', 'FIN', '246', 'Finland', 'Финляндия'),
+    ('FJ', 'FJI', '242', 'Fiji', 'Фиджи'),
+    ('FK', 'FLK', '238', 'Falkland Islands', 'Фолклендские острова'),
+    ('FM', 'FSM', '583', 'Micronesia', 'Федеративные Штаты Микронезии'),
+    ('FO', 'FRO', '234', 'Faroe Islands', 'Фарерские острова'),
+    ('FR', 'FRA', '250', 'France', 'Франция'),
+    ('GA', 'GAB', '266', 'Gabon', 'Габон'),
+    ('GB', 'GBR', '826', 'United Kingdom', 'Великобритания'),
+    ('GD', 'GRD', '308', 'Grenada', 'Гренада'),
+    ('GE', 'GEO', '268', 'Georgia', 'Грузия'),
+    ('GF', 'GUF', '254', 'French Guiana', 'Французская Гвиана'),
+    ('GG', 'GGY', '831', 'Guernsey', 'Гернси'),
+    ('GH', 'GHA', '288', 'Ghana', 'Гана'),
+    ('GI', 'GIB', '292', 'Gibraltar', 'Гибралтар'),
+    ('GL', 'GRL', '304', 'Greenland', 'Гренландия'),
+    ('GM', 'GMB', '270', 'Gambia', 'Гамбия'),
+    ('GN', 'GIN', '324', 'Guinea', 'Гвинея'),
+    ('GP', 'GLP', '312', 'Guadeloupe', 'Гваделупа'),
+    ('GQ', 'GNQ', '226', 'Equatorial Guinea', 'Экваториальная Гвинея'),
+    ('GR', 'GRC', '300', 'Greece', 'Греция'),
+    ('GS', 'SGS', '239', 'South Georgia and South Sandwich Islands', 'Южная Георгия и Южные Сандвичевы острова'),
+    ('GT', 'GTM', '320', 'Guatemala', 'Гватемала'),
+    ('GU', 'GUM', '316', 'Guam', 'Гуам'),
+    ('GW', 'GNB', '624', 'Guinea-Bissau', 'Гвинея-Бисау'),
+    ('GY', 'GUY', '328', 'Guyana', 'Гайана'),
+    ('HK', 'HKG', '344', 'Hong Kong', 'Гонконг'),
+    ('HM', 'HMD', '334', 'Heard and McDonald Islands', 'Острова Херд и Макдональд'),
+    ('HN', 'HND', '340', 'Honduras', 'Гондурас'),
+    ('HR', 'HRV', '191', 'Croatia', 'Хорватия'),
+    ('HT', 'HTI', '332', 'Haiti', 'Гаити'),
+    ('HU', 'HUN', '348', 'Hungary', 'Венгрия'),
+    ('ID', 'IDN', '360', 'Indonesia', 'Индонезия'),
+    ('IE', 'IRL', '372', 'Ireland', 'Ирландия'),
+    ('IL', 'ISR', '376', 'Israel', 'Израиль'),
+    ('IM', 'IMN', '833', 'Isle of Man', 'Остров Мэн'),
+    ('IN', 'IND', '356', 'India', 'Индия'),
+    ('IO', 'IOT', '086', 'British Indian Ocean Territory', 'Британская территория в Индийском океане'),
+    ('IQ', 'IRQ', '368', 'Iraq', 'Ирак'),
+    ('IR', 'IRN', '364', 'Iran', 'Иран'),
+    ('IS', 'ISL', '352', 'Iceland', 'Исландия'),
+    ('IT', 'ITA', '380', 'Italy', 'Италия'),
+    ('JE', 'JEY', '832', 'Jersey', 'Джерси'),
+    ('JM', 'JAM', '388', 'Jamaica', 'Ямайка'),
+    ('JO', 'JOR', '400', 'Jordan', 'Иордания'),
+    ('JP', 'JPN', '392', 'Japan', 'Япония'),
+    ('KE', 'KEN', '404', 'Kenya', 'Кения'),
+    ('KG', 'KGZ', '417', 'Kyrgyzstan', 'Киргизия'),
+    ('KH', 'KHM', '116', 'Cambodia', 'Камбоджа'),
+    ('KI', 'KIR', '296', 'Kiribati', 'Кирибати'),
+    ('KM', 'COM', '174', 'Comoros', 'Коморы'),
+    ('KN', 'KNA', '659', 'Saint Kitts and Nevis', 'Сент-Китс и Невис'),
+    ('KP', 'PRK', '408', 'North Korea', 'КНДР'),
+    ('KR', 'KOR', '410', 'South Korea', 'Республика Корея'),
+    ('KW', 'KWT', '414', 'Kuwait', 'Кувейт'),
+    ('KY', 'CYM', '136', 'Cayman Islands', 'Каймановы острова'),
+    ('KZ', 'KAZ', '398', 'Kazakhstan', 'Казахстан'),
+    ('LA', 'LAO', '418', 'Laos', 'Лаос'),
+    ('LB', 'LBN', '422', 'Lebanon', 'Ливан'),
+    ('LC', 'LCA', '662', 'Saint Lucia', 'Сент-Люсия'),
+    ('LI', 'LIE', '438', 'Liechtenstein', 'Лихтенштейн'),
+    ('LK', 'LKA', '144', 'Sri Lanka', 'Шри-Ланка'),
+    ('LR', 'LBR', '430', 'Liberia', 'Либерия'),
+    ('LS', 'LSO', '426', 'Lesotho', 'Лесото'),
+    ('LT', 'LTU', '440', 'Lithuania', 'Литва'),
+    ('LU', 'LUX', '442', 'Luxembourg', 'Люксембург'),
+    ('LV', 'LVA', '428', 'Latvia', 'Латвия'),
+    ('LY', 'LBY', '434', 'Libya', 'Ливия'),
+    ('MA', 'MAR', '504', 'Morocco', 'Марокко'),
+    ('MC', 'MCO', '492', 'Monaco', 'Монако'),
+    ('MD', 'MDA', '498', 'Moldova', 'Молдова'),
+    ('ME', 'MNE', '499', 'Montenegro', 'Черногория'),
+    ('MF', 'MAF', '663', 'Saint Martin', 'Сен-Мартен'),
+    ('MG', 'MDG', '450', 'Madagascar', 'Мадагаскар'),
+    ('MH', 'MHL', '584', 'Marshall Islands', 'Маршалловы Острова'),
+    ('MK', 'MKD', '807', 'North Macedonia', 'Северная Македония'),
+    ('ML', 'MLI', '466', 'Mali', 'Мали'),
+    ('MM', 'MMR', '104', 'Myanmar', 'Мьянма'),
+    ('MN', 'MNG', '496', 'Mongolia', 'Монголия'),
+    ('MO', 'MAC', '446', 'Macao', 'Макао'),
+    ('MP', 'MNP', '580', 'Northern Mariana Islands', 'Северные Марианские острова'),
+    ('MQ', 'MTQ', '474', 'Martinique', 'Мартиника'),
+    ('MR', 'MRT', '478', 'Mauritania', 'Мавритания'),
+    ('MS', 'MSR', '500', 'Montserrat', 'Монтсеррат'),
+    ('MT', 'MLT', '470', 'Malta', 'Мальта'),
+    ('MU', 'MUS', '480', 'Mauritius', 'Маврикий'),
+    ('MV', 'MDV', '462', 'Maldives', 'Мальдивы'),
+    ('MW', 'MWI', '454', 'Malawi', 'Малави'),
+    ('MX', 'MEX', '484', 'Mexico', 'Мексика'),
+    ('MY', 'MYS', '458', 'Malaysia', 'Малайзия'),
+    ('MZ', 'MOZ', '508', 'Mozambique', 'Мозамбик'),
+    ('NA', 'NAM', '516', 'Namibia', 'Намибия'),
+    ('NC', 'NCL', '540', 'New Caledonia', 'Новая Каледония'),
+    ('NE', 'NER', '562', 'Niger', 'Нигер'),
+    ('NF', 'NFK', '574', 'Norfolk Island', 'Остров Норфолк'),
+    ('NG', 'NGA', '566', 'Nigeria', 'Нигерия'),
+    ('NI', 'NIC', '558', 'Nicaragua', 'Никарагуа'),
+    ('NL', 'NLD', '528', 'Netherlands', 'Нидерланды'),
+    ('NO', 'NOR', '578', 'Norway', 'Норвегия'),
+    ('NP', 'NPL', '524', 'Nepal', 'Непал'),
+    ('NR', 'NRU', '520', 'Nauru', 'Науру'),
+    ('NU', 'NIU', '570', 'Niue', 'Ниуэ'),
+    ('NZ', 'NZL', '554', 'New Zealand', 'Новая Зеландия'),
+    ('OM', 'OMN', '512', 'Oman', 'Оман'),
+    ('PA', 'PAN', '591', 'Panama', 'Панама'),
+    ('PE', 'PER', '604', 'Peru', 'Перу'),
+    ('PF', 'PYF', '258', 'French Polynesia', 'Французская Полинезия'),
+    ('PG', 'PNG', '598', 'Papua New Guinea', 'Папуа — Новая Гвинея'),
+    ('PH', 'PHL', '608', 'Philippines', 'Филиппины'),
+    ('PK', 'PAK', '586', 'Pakistan', 'Пакистан'),
+    ('PL', 'POL', '616', 'Poland', 'Польша'),
+    ('PM', 'SPM', '666', 'Saint Pierre and Miquelon', 'Сен-Пьер и Микелон'),
+    ('PN', 'PCN', '612', 'Pitcairn Islands', 'Острова Питкэрн'),
+    ('PR', 'PRI', '630', 'Puerto Rico', 'Пуэрто-Рико'),
+    ('PS', 'PSE', '275', 'Palestine', 'Палестина'),
+    ('PT', 'PRT', '620', 'Portugal', 'Португалия'),
+    ('PW', 'PLW', '585', 'Palau', 'Палау'),
+    ('PY', 'PRY', '600', 'Paraguay', 'Парагвай'),
+    ('QA', 'QAT', '634', 'Qatar', 'Катар'),
+    ('RE', 'REU', '638', 'Réunion', 'Реюньон'),
+    ('RO', 'ROU', '642', 'Romania', 'Румыния'),
+    ('RS', 'SRB', '688', 'Serbia', 'Сербия'),
+    ('RU', 'RUS', '643', 'Russia', 'Россия'),
+    ('RW', 'RWA', '646', 'Rwanda', 'Руанда'),
+    ('SA', 'SAU', '682', 'Saudi Arabia', 'Саудовская Аравия'),
+    ('SB', 'SLB', '090', 'Solomon Islands', 'Соломоновы Острова'),
+    ('SC', 'SYC', '690', 'Seychelles', 'Сейшельские Острова'),
+    ('SD', 'SDN', '729', 'Sudan', 'Судан'),
+    ('SE', 'SWE', '752', 'Sweden', 'Швеция'),
+    ('SG', 'SGP', '702', 'Singapore', 'Сингапур'),
+    ('SH', 'SHN', '654', 'Saint Helena', 'Остров Святой Елены'),
+    ('SI', 'SVN', '705', 'Slovenia', 'Словения'),
+    ('SJ', 'SJM', '744', 'Svalbard and Jan Mayen', 'Шпицберген и Ян-Майен'),
+    ('SK', 'SVK', '703', 'Slovakia', 'Словакия'),
+    ('SL', 'SLE', '694', 'Sierra Leone', 'Сьерра-Леоне'),
+    ('SM', 'SMR', '674', 'San Marino', 'Сан-Марино'),
+    ('SN', 'SEN', '686', 'Senegal', 'Сенегал'),
+    ('SO', 'SOM', '706', 'Somalia', 'Сомали'),
+    ('SR', 'SUR', '740', 'Suriname', 'Суринам'),
+    ('SS', 'SSD', '728', 'South Sudan', 'Южный Судан'),
+    ('ST', 'STP', '678', 'São Tomé and Príncipe', 'Сан-Томе и Принсипи'),
+    ('SV', 'SLV', '222', 'El Salvador', 'Сальвадор'),
+    ('SX', 'SXM', '534', 'Sint Maarten', 'Синт-Мартен'),
+    ('SY', 'SYR', '760', 'Syria', 'Сирия'),
+    ('SZ', 'SWZ', '748', 'Eswatini', 'Эсватини'),
+    ('TC', 'TCA', '796', 'Turks and Caicos Islands', 'Острова Тёркс и Кайкос'),
+    ('TD', 'TCD', '148', 'Chad', 'Чад'),
+    ('TF', 'ATF', '260', 'French Southern Territories', 'Французские Южные территории'),
+    ('TG', 'TGO', '768', 'Togo', 'Того'),
+    ('TH', 'THA', '764', 'Thailand', 'Таиланд'),
+    ('TJ', 'TJK', '762', 'Tajikistan', 'Таджикистан'),
+    ('TK', 'TKL', '772', 'Tokelau', 'Токелау'),
+    ('TL', 'TLS', '626', 'Timor-Leste', 'Восточный Тимор'),
+    ('TM', 'TKM', '795', 'Turkmenistan', 'Туркменистан'),
+    ('TN', 'TUN', '788', 'Tunisia', 'Тунис'),
+    ('TO', 'TON', '776', 'Tonga', 'Тонга'),
+    ('TR', 'TUR', '792', 'Türkiye', 'Турция'),
+    ('TT', 'TTO', '780', 'Trinidad and Tobago', 'Тринидад и Тобаго'),
+    ('TV', 'TUV', '798', 'Tuvalu', 'Тувалу'),
+    ('TW', 'TWN', '158', 'Taiwan', 'Тайвань'),
+    ('TZ', 'TZA', '834', 'Tanzania', 'Танзания'),
+    ('UA', 'UKR', '804', 'Ukraine', 'Украина'),
+    ('UG', 'UGA', '800', 'Uganda', 'Уганда'),
+    ('UM', 'UMI', '581', 'U.S. Outlying Islands', 'Внешние малые острова (США)'),
+    ('US', 'USA', '840', 'United States', 'США'),
+    ('UY', 'URY', '858', 'Uruguay', 'Уругвай'),
+    ('UZ', 'UZB', '860', 'Uzbekistan', 'Узбекистан'),
+    ('VA', 'VAT', '336', 'Vatican City', 'Ватикан'),
+    ('VC', 'VCT', '670', 'Saint Vincent and Grenadines', 'Сент-Винсент и Гренадины'),
+    ('VE', 'VEN', '862', 'Venezuela', 'Венесуэла'),
+    ('VG', 'VGB', '092', 'British Virgin Islands', 'Виргинские острова (Британские)'),
+    ('VI', 'VIR', '850', 'U.S. Virgin Islands', 'Виргинские острова (США)'),
+    ('VN', 'VNM', '704', 'Vietnam', 'Вьетнам'),
+    ('VU', 'VUT', '548', 'Vanuatu', 'Вануату'),
+    ('WF', 'WLF', '876', 'Wallis and Futuna', 'Уоллис и Футуна'),
+    ('WS', 'WSM', '882', 'Samoa', 'Самоа'),
+    ('YE', 'YEM', '887', 'Yemen', 'Йемен'),
+    ('YT', 'MYT', '175', 'Mayotte', 'Майотта'),
+    ('ZA', 'ZAF', '710', 'South Africa', 'Южно-Африканская Республика'),
+    ('ZM', 'ZMB', '894', 'Zambia', 'Замбия'),
+    ('ZW', 'ZWE', '716', 'Zimbabwe', 'Зимбабве');
+
+-- Codes that are not ISO 3166-1 alpha-2 are cleared before the foreign key
+-- is added; the history keeps what they were.
+SELECT set_config('em.source', 'migration', true);
+
+UPDATE em_people1 SET nationality = upper(btrim(nationality)), version = version + 1
+WHERE nationality <> upper(btrim(nationality));
+
+UPDATE em_people1 SET nationality = '', nationality_probability = 0, version = version + 1
+WHERE nationality <> '' AND nationality NOT IN (SELECT alpha2 FROM em_countries);
+
+-- nationality stays NOT NULL with '' for unknown, so the foreign key is on a
+-- generated copy that has NULL in its place.
+ALTER TABLE em_people1
+    ADD COLUMN IF NOT EXISTS nationality_code varchar(50)
+    GENERATED ALWAYS AS (nullif(nationality, '')) STORED
+    REFERENCES em_countries (alpha2);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE em_people1 DROP COLUMN IF EXISTS nationality_code;
+DROP TABLE IF EXISTS em_countries;
+-- +goose StatementEnd
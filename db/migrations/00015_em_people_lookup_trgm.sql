@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- prefix and contains filters on names match lookup keys, like eq and in.
+CREATE INDEX IF NOT EXISTS em_people1_fname_lookup_trgm_idx ON em_people1 USING gin (em_lookup_key(fname) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS em_people1_surname_lookup_trgm_idx ON em_people1 USING gin (em_lookup_key(surname) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS em_people1_patronymic_lookup_trgm_idx ON em_people1 USING gin (em_lookup_key(patronymic) gin_trgm_ops);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS em_people1_patronymic_lookup_trgm_idx;
+DROP INDEX IF EXISTS em_people1_surname_lookup_trgm_idx;
+DROP INDEX IF EXISTS em_people1_fname_lookup_trgm_idx;
+-- +goose StatementEnd
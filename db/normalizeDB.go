@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeName is the form names are stored in: surrounding whitespace
+// trimmed, inner runs collapsed to one space, Unicode NFC, and every word
+// title-cased, including each part of a hyphenated name ("анна-мария" becomes
+// "Анна-Мария"). Cyrillic names are cased by Russian rules. The input as
+// sent is kept next to it in the raw_* columns.
+func NormalizeName(name string) string {
+	name = strings.Join(strings.Fields(norm.NFC.String(name)), " ")
+	return cases.Title(nameLanguage(name)).String(name)
+}
+
+func nameLanguage(name string) language.Tag {
+	for _, r := range name {
+		if unicode.Is(unicode.Cyrillic, r) {
+			return language.Russian
+		}
+	}
+	return language.Und
+}
+
+var yoReplacer = strings.NewReplacer("Ё", "Е", "ё", "е")
+
+// NameLookupKey is the key names are matched and cached by: the normalized
+// name in lower case with Ё folded into Е, as it is often written without
+// the dots. It matches em_lookup_key of the stored, normalized name.
+func NameLookupKey(name string) string {
+	return strings.ToLower(yoReplacer.Replace(NormalizeName(name)))
+}
+
+// BackfillNormalizedNames normalizes the names of people created before
+// names were normalized on write, keeping the stored values as their raw
+// input. It runs in small batches and is a no-op once every row has been
+// normalized.
+func (s *PostgresStorage) BackfillNormalizedNames() error {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		n, err := s.backfillNormalizedBatch(ctx, 500)
+		cancel()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+	}
+}
+
+func (s *PostgresStorage) backfillNormalizedBatch(ctx context.Context, size int) (int, error) {
+	n := 0
+	err := s.withChange(ctx, Change{Actor: "system", Source: SourceNormalize}, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `
+			select id, fname, surname, patronymic from em_people1
+			where raw_fname is null
+			limit $1
+			for update`, size)
+		if err != nil {
+			return fmt.Errorf("failed to read people for name backfill: %w", err)
+		}
+
+		type names struct {
+			id                         int
+			fname, surname, patronymic string
+		}
+		var batch []names
+		for rows.Next() {
+			var n names
+			if err := rows.Scan(&n.id, &n.fname, &n.surname, &n.patronymic); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan people for name backfill: %w", err)
+			}
+			batch = append(batch, n)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to read people for name backfill: %w", err)
+		}
+
+		for _, p := range batch {
+			fname, surname, patronymic := NormalizeName(p.fname), NormalizeName(p.surname), NormalizeName(p.patronymic)
+			// Rows already in normal form only get their raw columns, so
+			// the backfill adds no history or version bump for them.
+			bump := fname != p.fname || surname != p.surname || patronymic != p.patronymic
+			if _, err := tx.ExecContext(ctx, `
+				update em_people1 set
+					fname = $1, surname = $2, patronymic = $3,
+					fname_phonetic = $4, surname_phonetic = $5, patronymic_phonetic = $6,
+					raw_fname = $7, raw_surname = $8, raw_patronymic = $9,
+					version = case when $10 then version + 1 else version end
+				where id = $11`,
+				fname, surname, patronymic,
+				phoneticKey(fname), phoneticKey(surname), phoneticKey(patronymic),
+				p.fname, p.surname, p.patronymic, bump, p.id); err != nil {
+				return fmt.Errorf("failed to backfill normalized names: %w", err)
+			}
+		}
+		n = len(batch)
+		return nil
+	})
+	return n, err
+}
@@ -0,0 +1,69 @@
+package db
+
+import "testing"
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"   ", ""},
+		{"ivan", "Ivan"},
+		{"IVAN", "Ivan"},
+		{"  иВАН  ", "Иван"},
+		{"анна-мария", "Анна-Мария"},
+		{"ANNA-MARIA", "Anna-Maria"},
+		{"van  der\tberg", "Van Der Berg"},
+		{"ёлкина", "Ёлкина"},
+		{"o'neil", "O'neil"},
+		{"анна мария", "Анна Мария"},
+		// Decomposed input, a letter and a combining mark, is composed.
+		{"e\u0301mile", "\u00c9mile"},
+		{"\u0438\u0306\u043e\u0448\u043a\u0438\u043d", "Йошкин"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeName(tt.in); got != tt.want {
+			t.Errorf("NormalizeName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeNameIdempotent(t *testing.T) {
+	for _, in := range []string{"  анна-МАРИЯ ", "e\u0301mile", "Ёлкина", "van der berg"} {
+		once := NormalizeName(in)
+		if twice := NormalizeName(once); twice != once {
+			t.Errorf("NormalizeName(%q) = %q, again %q", in, once, twice)
+		}
+	}
+}
+
+func TestNameLookupKey(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"Ёлкина", "елкина", true},
+		{"  ЁЛКИНА ", "Елкина", true},
+		{"Анна  Мария", "анна мария", true},
+		{"e\u0301mile", "\u00c9MILE", true},
+		{"Анна-Мария", "Анна Мария", false},
+		{"Иван", "Ivan", false},
+	}
+	for _, tt := range tests {
+		ka, kb := NameLookupKey(tt.a), NameLookupKey(tt.b)
+		if (ka == kb) != tt.same {
+			t.Errorf("NameLookupKey(%q) = %q, NameLookupKey(%q) = %q, want same %v", tt.a, ka, tt.b, kb, tt.same)
+		}
+	}
+	if got := NameLookupKey("  ЁЛКИНА  анна "); got != "елкина анна" {
+		t.Errorf("NameLookupKey = %q", got)
+	}
+}
+
+func TestPhoneticKeyStableUnderNormalization(t *testing.T) {
+	for _, in := range []string{"  александр", "ALEXANDER", "анна-мария"} {
+		if got, want := phoneticKey(NormalizeName(in)), phoneticKey(in); got != want {
+			t.Errorf("phoneticKey(NormalizeName(%q)) = %q, want %q", in, got, want)
+		}
+	}
+}
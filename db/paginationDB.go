@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Keyset positions a page relative to the sort values of a known row. At most
+// one of After and Before is set; with neither set the first page is returned.
+type Keyset struct {
+	After  []interface{}
+	Before []interface{}
+}
+
+type CountMode string
+
+const (
+	CountNone     CountMode = "none"
+	CountExact    CountMode = "exact"
+	CountEstimate CountMode = "estimate"
+)
+
+// GetPeopleKeyset returns up to limit people in sort order, starting after or
+// ending before the keyset position. hasMore reports whether further rows exist
+// in the direction of travel.
+func (s *PostgresStorage) GetPeopleKeyset(filter PeopleFilter, sort Sort, keyset Keyset, limit int) ([]Person, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	backward := keyset.Before != nil
+	b := newPeopleSelect(personColumns, filter)
+	filter.apply(b)
+	switch {
+	case backward:
+		if len(keyset.Before) != len(sort) {
+			return nil, false, fmt.Errorf("%w: cursor does not match sort", ErrInvalidQuery)
+		}
+		b.where(sort.keysetCond(b, keyset.Before, true))
+	case keyset.After != nil:
+		if len(keyset.After) != len(sort) {
+			return nil, false, fmt.Errorf("%w: cursor does not match sort", ErrInvalidQuery)
+		}
+		b.where(sort.keysetCond(b, keyset.After, false))
+	}
+	b.order(sort.orderTerms(backward)...)
+	b.limit = limit + 1
+
+	query, args := b.build()
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to list people: %w", err)
+	}
+	defer rows.Close()
+
+	people := make([]Person, 0, limit+1)
+	for rows.Next() {
+		p, err := scanPerson(rows)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to scan person: %w", err)
+		}
+		people = append(people, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("failed to list people: %w", err)
+	}
+
+	hasMore := len(people) > limit
+	if hasMore {
+		people = people[:limit]
+	}
+	if backward {
+		for i, j := 0, len(people)-1; i < j; i, j = i+1, j-1 {
+			people[i], people[j] = people[j], people[i]
+		}
+	}
+
+	return people, hasMore, nil
+}
+
+// CountPeople counts people matching filter. CountEstimate asks the planner
+// instead of scanning, which is cheap but may be off for selective filters.
+func (s *PostgresStorage) CountPeople(filter PeopleFilter, mode CountMode) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	b := newPeopleSelect("1", filter)
+	filter.apply(b)
+	countQuery, args := b.countSQL()
+	switch mode {
+	case CountExact:
+		var total int
+		if err := s.db.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+			return 0, fmt.Errorf("failed to count people: %w", err)
+		}
+		return total, nil
+
+	case CountEstimate:
+		var plan []byte
+		query, _ := b.build()
+		if err := s.db.QueryRowContext(ctx, `EXPLAIN (FORMAT JSON) `+query, args...).Scan(&plan); err != nil {
+			return 0, fmt.Errorf("failed to estimate people count: %w", err)
+		}
+		var explained []struct {
+			Plan struct {
+				Rows float64 `json:"Plan Rows"`
+			} `json:"Plan"`
+		}
+		if err := json.Unmarshal(plan, &explained); err != nil || len(explained) == 0 {
+			return 0, fmt.Errorf("failed to parse query plan: %v", err)
+		}
+		return int(explained[0].Plan.Rows), nil
+	}
+
+	return 0, fmt.Errorf("unknown count mode %q", mode)
+}
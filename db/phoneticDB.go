@@ -0,0 +1,137 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var cyrillicToLatin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "i", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "",
+	'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya", 'і': "i", 'ї': "i", 'є': "e",
+}
+
+// Digraphs are folded before single letters; order matters ("shch" before
+// "sh", "kh" before "k").
+var phoneticDigraphs = strings.NewReplacer(
+	"shch", "S", "sch", "S", "sh", "S", "zh", "J", "ch", "C", "tch", "C",
+	"kh", "H", "ts", "C", "tz", "C", "ph", "F", "th", "T", "ck", "K",
+	"ks", "KS", "x", "KS", "qu", "KV", "q", "K", "w", "V", "dzh", "J",
+	"ce", "Se", "ci", "Si", "cy", "Sy", "c", "K",
+)
+
+var phoneticClasses = map[rune]rune{
+	'b': 'P', 'p': 'P', 'v': 'F', 'f': 'F', 'F': 'F', 'P': 'P', 'V': 'F',
+	'g': 'K', 'k': 'K', 'K': 'K', 'd': 'T', 't': 'T', 'T': 'T',
+	'z': 'S', 's': 'S', 'S': 'S', 'j': 'J', 'J': 'J', 'C': 'C',
+	'h': 'H', 'H': 'H', 'l': 'L', 'm': 'M', 'n': 'N', 'r': 'R',
+}
+
+// phoneticKey reduces a name to a consonant skeleton that is stable across
+// Cyrillic and common Latin transliterations, so "Александр", "Aleksandr" and
+// "Alexander" share a key. Cyrillic is transliterated first, then digraphs
+// are folded, voiced/voiceless pairs merged, vowels dropped after the first
+// letter and repeats collapsed. A leading vowel of any kind becomes 'A'.
+func phoneticKey(name string) string {
+	var latin strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if t, ok := cyrillicToLatin[r]; ok {
+			latin.WriteString(t)
+		} else if r < unicode.MaxASCII && unicode.IsLetter(r) {
+			latin.WriteRune(r)
+		} else if unicode.IsSpace(r) || r == '-' {
+			latin.WriteRune(' ')
+		}
+	}
+
+	var keys []string
+	for _, word := range strings.Fields(latin.String()) {
+		folded := phoneticDigraphs.Replace(word)
+
+		var key []rune
+		var last rune
+		for i, r := range folded {
+			class, consonant := phoneticClasses[r]
+			if !consonant {
+				if i == 0 {
+					key = append(key, 'A')
+				}
+				last = 0
+				continue
+			}
+			if class != last {
+				key = append(key, class)
+			}
+			last = class
+		}
+		if len(key) > 0 {
+			keys = append(keys, string(key))
+		}
+	}
+	return strings.Join(keys, " ")
+}
+
+// BackfillPhoneticKeys fills phonetic keys for rows written before the
+// columns existed. It runs in small batches and is a no-op once every row has
+// keys.
+func (s *PostgresStorage) BackfillPhoneticKeys() error {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		n, err := s.backfillPhoneticBatch(ctx, 500)
+		cancel()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+	}
+}
+
+func (s *PostgresStorage) backfillPhoneticBatch(ctx context.Context, size int) (int, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		select id, fname, surname, patronymic from em_people1
+		where fname_phonetic = '' and fname <> ''
+		limit $1`, size)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read people for phonetic backfill: %w", err)
+	}
+
+	type names struct {
+		id                         int
+		fname, surname, patronymic string
+	}
+	var batch []names
+	for rows.Next() {
+		var n names
+		if err := rows.Scan(&n.id, &n.fname, &n.surname, &n.patronymic); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan people for phonetic backfill: %w", err)
+		}
+		batch = append(batch, n)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read people for phonetic backfill: %w", err)
+	}
+
+	for _, n := range batch {
+		fnameKey := phoneticKey(n.fname)
+		if fnameKey == "" {
+			// Names without any letters would be picked up again forever.
+			fnameKey = "-"
+		}
+		if _, err := s.db.ExecContext(ctx, `
+			update em_people1 set fname_phonetic = $1, surname_phonetic = $2, patronymic_phonetic = $3
+			where id = $4`,
+			fnameKey, phoneticKey(n.surname), phoneticKey(n.patronymic), n.id); err != nil {
+			return 0, fmt.Errorf("failed to backfill phonetic keys: %w", err)
+		}
+	}
+	return len(batch), nil
+}
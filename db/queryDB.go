@@ -0,0 +1,224 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidQuery marks listing options (sort, filter, cursor) rejected
+// before any SQL is run. Handlers map it to 400.
+var ErrInvalidQuery = errors.New("invalid query")
+
+// selectBuilder assembles a parameterised SELECT. Identifiers only ever come
+// from the whitelists in this package; every value goes through bind.
+type selectBuilder struct {
+	columns string
+	from    string
+	conds   []string
+	args    []interface{}
+	orderBy []string
+	limit   int
+	offset  int
+}
+
+func newSelect(columns, from string) *selectBuilder {
+	return &selectBuilder{columns: columns, from: from}
+}
+
+// Tombstones left behind by merges are never listed; soft-deleted rows only
+// when asked for. Single-row reads and writes use livePeople.
+const (
+	notMerged  = "merged_into IS NULL"
+	notDeleted = "deleted_at IS NULL"
+	livePeople = notMerged + " AND " + notDeleted
+)
+
+// newPeopleSelect starts every listing of em_people1. With filter.AsOf set
+// it lists the people as they were at that time instead.
+func newPeopleSelect(columns string, filter PeopleFilter) *selectBuilder {
+	b := newSelect(columns, "em_people1")
+	if filter.AsOf != nil {
+		b.from = fmt.Sprintf("em_people_as_of(%s) AS em_people1", b.bind(*filter.AsOf))
+	}
+	b.where(notMerged)
+	if !filter.IncludeDeleted {
+		b.where(notDeleted)
+	}
+	return b
+}
+
+// bind registers v as the next positional argument and returns its
+// placeholder.
+func (b *selectBuilder) bind(v interface{}) string {
+	b.args = append(b.args, v)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+func (b *selectBuilder) where(cond string) *selectBuilder {
+	b.conds = append(b.conds, cond)
+	return b
+}
+
+func (b *selectBuilder) order(terms ...string) *selectBuilder {
+	b.orderBy = append(b.orderBy, terms...)
+	return b
+}
+
+func (b *selectBuilder) whereSQL() string {
+	if len(b.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(b.conds, " AND ")
+}
+
+// build renders the full statement. LIMIT and OFFSET are bound last on a copy
+// of the arguments, so the builder can still render countSQL afterwards.
+func (b *selectBuilder) build() (string, []interface{}) {
+	args := append([]interface{}(nil), b.args...)
+	query := "SELECT " + b.columns + " FROM " + b.from + b.whereSQL()
+	if len(b.orderBy) > 0 {
+		query += " ORDER BY " + strings.Join(b.orderBy, ", ")
+	}
+	if b.limit > 0 {
+		args = append(args, b.limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if b.offset > 0 {
+		args = append(args, b.offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+	return query, args
+}
+
+func (b *selectBuilder) countSQL() (string, []interface{}) {
+	return "SELECT count(*) FROM " + b.from + b.whereSQL(), b.args
+}
+
+// sortableColumns maps API field names to the columns they sort by.
+var sortableColumns = map[string]string{
+	"id":          "id",
+	"name":        "fname",
+	"surname":     "surname",
+	"patronymic":  "patronymic",
+	"age":         "age",
+	"gender":      "gender",
+	"nationality": "nationality",
+	"updated_at":  "updated_at",
+}
+
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// Sort is an ordered list of sort keys. Sorts produced by ParseSort always end
+// with id, which makes the order total and keyset cursors unambiguous.
+type Sort []SortField
+
+var DefaultSort = Sort{{Field: "id"}}
+
+// ParseSort parses a spec like "surname,-age,id". A leading '-' means
+// descending. Unknown or repeated fields are rejected.
+func ParseSort(spec string) (Sort, error) {
+	if strings.TrimSpace(spec) == "" {
+		return DefaultSort, nil
+	}
+
+	var sort Sort
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		field := SortField{Field: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+		if _, ok := sortableColumns[field.Field]; !ok {
+			return nil, fmt.Errorf("%w: cannot sort by %q", ErrInvalidQuery, field.Field)
+		}
+		if seen[field.Field] {
+			return nil, fmt.Errorf("%w: %q appears in sort more than once", ErrInvalidQuery, field.Field)
+		}
+		seen[field.Field] = true
+		sort = append(sort, field)
+	}
+	if !seen["id"] {
+		sort = append(sort, SortField{Field: "id"})
+	}
+	return sort, nil
+}
+
+func (s Sort) String() string {
+	parts := make([]string, len(s))
+	for i, f := range s {
+		parts[i] = f.Field
+		if f.Desc {
+			parts[i] = "-" + f.Field
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// orderTerms renders the ORDER BY list, optionally reversed for paging
+// backwards.
+func (s Sort) orderTerms(reverse bool) []string {
+	terms := make([]string, len(s))
+	for i, f := range s {
+		dir := "ASC"
+		if f.Desc != reverse {
+			dir = "DESC"
+		}
+		terms[i] = sortableColumns[f.Field] + " " + dir
+	}
+	return terms
+}
+
+// Values returns p's values for every sort key, in order. They are what a
+// keyset cursor stores.
+func (s Sort) Values(p Person) []interface{} {
+	values := make([]interface{}, len(s))
+	for i, f := range s {
+		switch f.Field {
+		case "id":
+			values[i] = p.ID
+		case "name":
+			values[i] = p.Name
+		case "surname":
+			values[i] = p.Surname
+		case "patronymic":
+			values[i] = p.Patronymic
+		case "age":
+			values[i] = p.Age
+		case "gender":
+			values[i] = p.Gender
+		case "nationality":
+			values[i] = p.Nationality
+		case "updated_at":
+			values[i] = p.UpdatedAt
+		}
+	}
+	return values
+}
+
+// keysetCond renders the predicate selecting rows strictly after (or, with
+// backward set, strictly before) values in sort order. Mixed directions rule
+// out a plain row comparison, so it expands to
+// (a > $1) OR (a = $1 AND b < $2) OR ...
+func (s Sort) keysetCond(b *selectBuilder, values []interface{}, backward bool) string {
+	placeholders := make([]string, len(s))
+	for i := range s {
+		placeholders[i] = b.bind(values[i])
+	}
+
+	var alternatives []string
+	for i, f := range s {
+		var terms []string
+		for j := 0; j < i; j++ {
+			terms = append(terms, fmt.Sprintf("%s = %s", sortableColumns[s[j].Field], placeholders[j]))
+		}
+		op := ">"
+		if f.Desc != backward {
+			op = "<"
+		}
+		terms = append(terms, fmt.Sprintf("%s %s %s", sortableColumns[f.Field], op, placeholders[i]))
+		alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")"
+}
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    string
+		wantErr bool
+	}{
+		{spec: "", want: "id"},
+		{spec: "   ", want: "id"},
+		{spec: "surname", want: "surname,id"},
+		{spec: "surname,-age", want: "surname,-age,id"},
+		{spec: "-id", want: "-id"},
+		{spec: "age, -name ,id", want: "age,-name,id"},
+		{spec: "password", wantErr: true},
+		{spec: "age,-age", wantErr: true},
+		{spec: "age,", wantErr: true},
+	}
+	for _, tt := range tests {
+		sort, err := ParseSort(tt.spec)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidQuery) {
+				t.Errorf("ParseSort(%q) error = %v, want ErrInvalidQuery", tt.spec, err)
+			}
+			continue
+		}
+		if err != nil || sort.String() != tt.want {
+			t.Errorf("ParseSort(%q) = %q, %v, want %q", tt.spec, sort, err, tt.want)
+		}
+	}
+}
+
+func TestSortOrderTerms(t *testing.T) {
+	sort := Sort{{Field: "surname"}, {Field: "age", Desc: true}, {Field: "id"}}
+
+	want := []string{"surname ASC", "age DESC", "id ASC"}
+	if got := sort.orderTerms(false); !reflect.DeepEqual(got, want) {
+		t.Errorf("orderTerms(false) = %v, want %v", got, want)
+	}
+	want = []string{"surname DESC", "age ASC", "id DESC"}
+	if got := sort.orderTerms(true); !reflect.DeepEqual(got, want) {
+		t.Errorf("orderTerms(true) = %v, want %v", got, want)
+	}
+}
+
+func TestSelectBuilder(t *testing.T) {
+	b := newSelect("id", "em_people1")
+	b.where("age > " + b.bind(18))
+	b.order("id ASC")
+	b.limit, b.offset = 10, 20
+
+	query, args := b.build()
+	wantQuery := "SELECT id FROM em_people1 WHERE age > $1 ORDER BY id ASC LIMIT $2 OFFSET $3"
+	if query != wantQuery {
+		t.Errorf("build() query =\n%s\nwant\n%s", query, wantQuery)
+	}
+	if !reflect.DeepEqual(args, []interface{}{18, 10, 20}) {
+		t.Errorf("build() args = %v", args)
+	}
+
+	// build binds limit and offset on a copy, so counting still works.
+	count, countArgs := b.countSQL()
+	if count != "SELECT count(*) FROM em_people1 WHERE age > $1" {
+		t.Errorf("countSQL() = %s", count)
+	}
+	if !reflect.DeepEqual(countArgs, []interface{}{18}) {
+		t.Errorf("countSQL() args = %v", countArgs)
+	}
+}
+
+func TestPeopleSelectScopes(t *testing.T) {
+	live, _ := newPeopleSelect("id", PeopleFilter{}).build()
+	if live != "SELECT id FROM em_people1 WHERE merged_into IS NULL AND deleted_at IS NULL" {
+		t.Errorf("live: %s", live)
+	}
+	withDeleted, _ := newPeopleSelect("id", PeopleFilter{IncludeDeleted: true}).build()
+	if withDeleted != "SELECT id FROM em_people1 WHERE merged_into IS NULL" {
+		t.Errorf("include deleted: %s", withDeleted)
+	}
+
+	asOf := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	query, args := newPeopleSelect("id", PeopleFilter{AsOf: &asOf}).build()
+	if query != "SELECT id FROM em_people_as_of($1) AS em_people1 WHERE merged_into IS NULL AND deleted_at IS NULL" {
+		t.Errorf("as of: %s", query)
+	}
+	if len(args) != 1 || args[0] != asOf {
+		t.Errorf("as of args = %v", args)
+	}
+}
+
+func TestKeysetCond(t *testing.T) {
+	sort := Sort{{Field: "surname"}, {Field: "age", Desc: true}, {Field: "id"}}
+	values := []interface{}{"Иванов", 30, 42}
+
+	tests := []struct {
+		backward bool
+		want     string
+	}{
+		{false, "((surname > $1) OR (surname = $1 AND age < $2) OR (surname = $1 AND age = $2 AND id > $3))"},
+		{true, "((surname < $1) OR (surname = $1 AND age > $2) OR (surname = $1 AND age = $2 AND id < $3))"},
+	}
+	for _, tt := range tests {
+		b := newSelect("id", "em_people1")
+		if got := sort.keysetCond(b, values, tt.backward); got != tt.want {
+			t.Errorf("keysetCond(backward=%v) =\n%s\nwant\n%s", tt.backward, got, tt.want)
+		}
+		if !reflect.DeepEqual(b.args, values) {
+			t.Errorf("args = %v, want %v", b.args, values)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	MaxCrosstabRows = 250
+	MaxCrosstabCols = 50
+)
+
+type CrosstabMeasure string
+
+const (
+	MeasureCount  CrosstabMeasure = "count"
+	MeasureAvgAge CrosstabMeasure = "avg_age"
+)
+
+var crosstabMeasures = map[CrosstabMeasure]string{
+	MeasureCount:  "count(*)::float8",
+	MeasureAvgAge: "avg(age)::float8",
+}
+
+// crosstabDimensions lists what a crosstab may be broken down by. age_band
+// groups ages into bands of CrosstabOptions.AgeBand years.
+var crosstabDimensions = map[string]bool{"nationality": true, "gender": true, "age_band": true}
+
+type CrosstabOptions struct {
+	Rows    string
+	Cols    string
+	Measure CrosstabMeasure
+	AgeBand int
+}
+
+// Crosstab is a rows × cols matrix of the measure. A nil cell means no people
+// fall into that combination. Totals are computed by the database over the
+// underlying rows, so avg_age totals are true averages, not averages of cells.
+type Crosstab struct {
+	Rows       string          `json:"rows"`
+	Cols       string          `json:"cols"`
+	Measure    CrosstabMeasure `json:"measure"`
+	RowKeys    []string        `json:"row_keys"`
+	ColKeys    []string        `json:"col_keys"`
+	Values     [][]*float64    `json:"values"`
+	RowTotals  []*float64      `json:"row_totals"`
+	ColTotals  []*float64      `json:"col_totals"`
+	GrandTotal *float64        `json:"grand_total"`
+}
+
+func (o CrosstabOptions) validate() error {
+	if !crosstabDimensions[o.Rows] || !crosstabDimensions[o.Cols] {
+		return fmt.Errorf("%w: rows and cols must be one of nationality, gender, age_band", ErrInvalidQuery)
+	}
+	if o.Rows == o.Cols {
+		return fmt.Errorf("%w: rows and cols must differ", ErrInvalidQuery)
+	}
+	if _, ok := crosstabMeasures[o.Measure]; !ok {
+		return fmt.Errorf("%w: measure must be count or avg_age", ErrInvalidQuery)
+	}
+	if o.AgeBand < 1 {
+		return fmt.Errorf("%w: age band must be positive", ErrInvalidQuery)
+	}
+	return nil
+}
+
+func (o CrosstabOptions) dimensionSQL(b *selectBuilder, dim string) string {
+	if dim == "age_band" {
+		band := b.bind(o.AgeBand)
+		return fmt.Sprintf("((age / %[1]s) * %[1]s)", band)
+	}
+	return dim
+}
+
+func (o CrosstabOptions) label(dim, key string) string {
+	if dim != "age_band" {
+		return key
+	}
+	from, _ := strconv.Atoi(key)
+	return fmt.Sprintf("%d-%d", from, from+o.AgeBand-1)
+}
+
+// GetCrosstab aggregates people matching filter into a crosstab. Distinct row
+// and column keys are counted first and the report is refused if either
+// exceeds its limit, so wide breakdowns never reach the aggregation.
+func (s *PostgresStorage) GetCrosstab(filter PeopleFilter, opts CrosstabOptions) (Crosstab, error) {
+	report := Crosstab{Rows: opts.Rows, Cols: opts.Cols, Measure: opts.Measure}
+	if err := opts.validate(); err != nil {
+		return report, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	b := newPeopleSelect("", filter)
+	r := opts.dimensionSQL(b, opts.Rows)
+	c := opts.dimensionSQL(b, opts.Cols)
+	filter.apply(b)
+
+	b.columns = fmt.Sprintf("count(DISTINCT %s), count(DISTINCT %s)", r, c)
+	query, args := b.build()
+	var nRows, nCols int
+	if err := s.db.QueryRowContext(ctx, query, args...).Scan(&nRows, &nCols); err != nil {
+		return report, fmt.Errorf("failed to check crosstab size: %w", err)
+	}
+	if nRows > MaxCrosstabRows || nCols > MaxCrosstabCols {
+		return report, fmt.Errorf("%w: crosstab would have %d rows and %d columns, limits are %d and %d; narrow the filter",
+			ErrInvalidQuery, nRows, nCols, MaxCrosstabRows, MaxCrosstabCols)
+	}
+
+	b.columns = fmt.Sprintf("%[1]s::text, %[2]s::text, GROUPING(%[1]s, %[2]s), %[3]s", r, c, crosstabMeasures[opts.Measure])
+	query, args = b.build()
+	query += fmt.Sprintf(" GROUP BY GROUPING SETS ((%[1]s, %[2]s), (%[1]s), (%[2]s), ())", r, c)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return report, fmt.Errorf("failed to build crosstab: %w", err)
+	}
+	defer rows.Close()
+
+	type cell struct {
+		row, col string
+		value    float64
+	}
+	var cells []cell
+	rowTotals := make(map[string]float64)
+	colTotals := make(map[string]float64)
+	for rows.Next() {
+		var row, col sql.NullString
+		var grouping int
+		var value float64
+		if err := rows.Scan(&row, &col, &grouping, &value); err != nil {
+			return report, fmt.Errorf("failed to scan crosstab: %w", err)
+		}
+		switch grouping {
+		case 0:
+			cells = append(cells, cell{row.String, col.String, value})
+		case 1:
+			rowTotals[row.String] = value
+		case 2:
+			colTotals[col.String] = value
+		case 3:
+			v := value
+			report.GrandTotal = &v
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return report, fmt.Errorf("failed to build crosstab: %w", err)
+	}
+
+	rowKeys := sortedKeys(rowTotals, opts.Rows == "age_band")
+	colKeys := sortedKeys(colTotals, opts.Cols == "age_band")
+	rowIndex := make(map[string]int, len(rowKeys))
+	colIndex := make(map[string]int, len(colKeys))
+
+	report.Values = make([][]*float64, len(rowKeys))
+	for i, key := range rowKeys {
+		rowIndex[key] = i
+		report.Values[i] = make([]*float64, len(colKeys))
+		v := rowTotals[key]
+		report.RowTotals = append(report.RowTotals, &v)
+		report.RowKeys = append(report.RowKeys, opts.label(opts.Rows, key))
+	}
+	for j, key := range colKeys {
+		colIndex[key] = j
+		v := colTotals[key]
+		report.ColTotals = append(report.ColTotals, &v)
+		report.ColKeys = append(report.ColKeys, opts.label(opts.Cols, key))
+	}
+	for _, c := range cells {
+		v := c.value
+		report.Values[rowIndex[c.row]][colIndex[c.col]] = &v
+	}
+
+	return report, nil
+}
+
+func sortedKeys(m map[string]float64, numeric bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if numeric {
+			a, _ := strconv.Atoi(keys[i])
+			b, _ := strconv.Atoi(keys[j])
+			return a < b
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+)
+
+// DefaultDeletedRetention is how long soft-deleted people are kept before
+// PurgeDeleted removes them for good.
+const DefaultDeletedRetention = 30 * 24 * time.Hour
+
+// DeletedRetention reads DELETED_RETENTION, a duration like 720h, falling
+// back to DefaultDeletedRetention when it is not set.
+func DeletedRetention() (time.Duration, error) {
+	env := os.Getenv("DELETED_RETENTION")
+	if env == "" {
+		return DefaultDeletedRetention, nil
+	}
+	retention, err := time.ParseDuration(env)
+	if err != nil || retention < 0 {
+		return 0, fmt.Errorf("invalid DELETED_RETENTION %q: expected a duration like 720h", env)
+	}
+	return retention, nil
+}
+
+// PurgeDeleted permanently removes people soft-deleted more than retention
+// ago, together with the tombstones merged into them. It returns the number
+// of purged people.
+func (s *PostgresStorage) PurgeDeleted(retention time.Duration) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	var n int64
+	err := s.withChange(ctx, Change{Actor: "retention", Source: SourcePurge}, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx,
+			`DELETE FROM em_people1 WHERE deleted_at < now() - $1 * interval '1 second'`,
+			retention.Seconds())
+		if err != nil {
+			return fmt.Errorf("failed to purge deleted people: %w", err)
+		}
+
+		n, err = result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to check affected rows: %w", err)
+		}
+		return nil
+	})
+	return n, err
+}
@@ -0,0 +1,27 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeletedRetention(t *testing.T) {
+	tests := []struct {
+		env     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{env: "", want: DefaultDeletedRetention},
+		{env: "48h", want: 48 * time.Hour},
+		{env: "0s", want: 0},
+		{env: "-1h", wantErr: true},
+		{env: "30d", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Setenv("DELETED_RETENTION", tt.env)
+		got, err := DeletedRetention()
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("DELETED_RETENTION=%q: %v, %v, want %v, error %v", tt.env, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// fullNameExpr matches the expression of em_people1_full_name_trgm_idx.
+const fullNameExpr = `(fname || ' ' || surname || ' ' || patronymic)`
+
+// phoneticBonus is added to the trigram score when any word of the query
+// sounds like one of the name fields.
+const phoneticBonus = 0.5
+
+// SearchPeople finds people whose names are similar to q, either by trigram
+// word similarity or by phonetic key, on top of filter. Results are ranked by
+// score unless sort is given; every person carries its score.
+func (s *PostgresStorage) SearchPeople(q string, filter PeopleFilter, sort Sort, limit, offset int) ([]Person, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if filter.AsOf != nil {
+		return nil, 0, fmt.Errorf("%w: search does not support as_of", ErrInvalidQuery)
+	}
+
+	b := newPeopleSelect("", filter)
+	qp := b.bind(q)
+	keys := b.bind(pq.Array(strings.Fields(phoneticKey(q))))
+	phonetic := fmt.Sprintf("(fname_phonetic = ANY(%[1]s::text[]) OR surname_phonetic = ANY(%[1]s::text[]) OR patronymic_phonetic = ANY(%[1]s::text[]))", keys)
+
+	b.columns = fmt.Sprintf("%s, word_similarity(%s, %s) + CASE WHEN %s THEN %v ELSE 0 END AS score",
+		personColumns, qp, fullNameExpr, phonetic, phoneticBonus)
+	b.where(fmt.Sprintf("(%s <%% %s OR %s)", qp, fullNameExpr, phonetic))
+	filter.apply(b)
+
+	if sort != nil {
+		b.order(sort.orderTerms(false)...)
+	} else {
+		b.order("score DESC", "id ASC")
+	}
+	b.limit, b.offset = limit, offset
+
+	query, args := b.build()
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search people: %w", err)
+	}
+	defer rows.Close()
+
+	var people []Person
+	for rows.Next() {
+		var score float64
+		p, err := scanPerson(rows, &score)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan person: %w", err)
+		}
+		p.Score = &score
+		people = append(people, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to search people: %w", err)
+	}
+
+	countQuery, countArgs := b.countSQL()
+	var total int
+	if err := s.db.QueryRowContext(ctx, countQuery, countArgs...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count people: %w", err)
+	}
+
+	return people, total, nil
+}
@@ -0,0 +1,213 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type StatsSource string
+
+const (
+	StatsLive         StatsSource = "live"
+	StatsMaterialized StatsSource = "materialized"
+)
+
+// statsViewFields are the fields kept in em_people_stats_mv, and so the only
+// ones a filter may use when reading from it.
+var statsViewFields = map[string]bool{"nationality": true, "gender": true, "age": true}
+
+type StatsBucket struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+type AgeBucket struct {
+	From  int `json:"from"`
+	To    int `json:"to"`
+	Count int `json:"count"`
+}
+
+type NationalityAge struct {
+	Nationality string  `json:"nationality"`
+	Count       int     `json:"count"`
+	MeanAge     float64 `json:"mean_age"`
+	MedianAge   int     `json:"median_age"`
+}
+
+type PeopleStats struct {
+	Source           StatsSource      `json:"source"`
+	RefreshedAt      *time.Time       `json:"refreshed_at,omitempty"`
+	Total            int              `json:"total"`
+	ByGender         []StatsBucket    `json:"by_gender"`
+	ByNationality    []StatsBucket    `json:"by_nationality"`
+	AgeHistogram     []AgeBucket      `json:"age_histogram"`
+	AgeByNationality []NationalityAge `json:"age_by_nationality"`
+}
+
+type StatsOptions struct {
+	Source         StatsSource
+	TopN           int
+	AgeBucketWidth int
+}
+
+const statsOtherKey = "other"
+
+// GetPeopleStats computes distributions over the people matching filter. Every
+// aggregate works on (nationality, gender, age, n) rows, where n is 1 for the
+// live table and the group size for the materialized view, so both sources
+// share the same SQL. All queries run in one repeatable-read snapshot.
+func (s *PostgresStorage) GetPeopleStats(filter PeopleFilter, opts StatsOptions) (PeopleStats, error) {
+	stats := PeopleStats{Source: opts.Source}
+
+	var b *selectBuilder
+	switch opts.Source {
+	case StatsMaterialized:
+		if filter.IncludeDeleted {
+			return stats, fmt.Errorf("%w: materialized stats never include deleted people", ErrInvalidQuery)
+		}
+		for _, c := range filter.Conditions {
+			if !statsViewFields[c.Field] {
+				return stats, fmt.Errorf("%w: materialized stats can only be filtered by nationality, gender and age", ErrInvalidQuery)
+			}
+		}
+		b = newSelect("nationality, gender, age, n", "em_people_stats_mv")
+	default:
+		stats.Source = StatsLive
+		b = newPeopleSelect("nationality, gender, age, 1 AS n", filter)
+	}
+	filter.apply(b)
+	base, args := b.build()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return stats, fmt.Errorf("failed to begin stats: %w", err)
+	}
+	defer tx.Rollback()
+
+	with := "WITH base AS (" + base + ") "
+	argN := len(args)
+
+	if stats.Source == StatsMaterialized {
+		if err := tx.QueryRowContext(ctx, `SELECT max(refreshed_at) FROM em_people_stats_mv`).Scan(&stats.RefreshedAt); err != nil {
+			return stats, fmt.Errorf("failed to read stats refresh time: %w", err)
+		}
+	}
+
+	if err := tx.QueryRowContext(ctx, with+`SELECT coalesce(sum(n), 0) FROM base`, args...).Scan(&stats.Total); err != nil {
+		return stats, fmt.Errorf("failed to count people: %w", err)
+	}
+
+	stats.ByGender, err = queryBuckets(ctx, tx, with+`
+		SELECT gender, sum(n) FROM base GROUP BY gender ORDER BY sum(n) DESC, gender`, args...)
+	if err != nil {
+		return stats, err
+	}
+
+	stats.ByNationality, err = queryBuckets(ctx, tx, with+fmt.Sprintf(`,
+		ranked AS (
+			SELECT nationality, sum(n) AS n, row_number() OVER (ORDER BY sum(n) DESC, nationality) AS rn
+			FROM base GROUP BY nationality
+		)
+		SELECT CASE WHEN rn <= $%[1]d THEN nationality ELSE '%[2]s' END AS key, sum(n)
+		FROM ranked
+		GROUP BY key, rn <= $%[1]d
+		ORDER BY rn <= $%[1]d DESC, sum(n) DESC, key`, argN+1, statsOtherKey), append(args, opts.TopN)...)
+	if err != nil {
+		return stats, err
+	}
+
+	rows, err := tx.QueryContext(ctx, with+fmt.Sprintf(`
+		SELECT (age / $%[1]d) * $%[1]d AS bucket, sum(n) FROM base GROUP BY bucket ORDER BY bucket`, argN+1),
+		append(args, opts.AgeBucketWidth)...)
+	if err != nil {
+		return stats, fmt.Errorf("failed to build age histogram: %w", err)
+	}
+	for rows.Next() {
+		var bucket AgeBucket
+		if err := rows.Scan(&bucket.From, &bucket.Count); err != nil {
+			rows.Close()
+			return stats, fmt.Errorf("failed to scan age histogram: %w", err)
+		}
+		bucket.To = bucket.From + opts.AgeBucketWidth - 1
+		stats.AgeHistogram = append(stats.AgeHistogram, bucket)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return stats, fmt.Errorf("failed to build age histogram: %w", err)
+	}
+
+	// The median is read off the cumulative age distribution, which works for
+	// both weighted (view) and unweighted (table) rows.
+	rows, err = tx.QueryContext(ctx, with+fmt.Sprintf(`,
+		top AS (
+			SELECT nationality FROM base GROUP BY nationality
+			ORDER BY sum(n) DESC, nationality LIMIT $%d
+		),
+		ages AS (
+			SELECT nationality, age, sum(n) AS n FROM base
+			WHERE nationality IN (SELECT nationality FROM top)
+			GROUP BY nationality, age
+		),
+		cumulative AS (
+			SELECT nationality, age, n,
+				sum(n) OVER (PARTITION BY nationality ORDER BY age) AS running,
+				sum(n) OVER (PARTITION BY nationality) AS total
+			FROM ages
+		)
+		SELECT nationality, sum(n), sum(age * n)::float8 / sum(n)::float8,
+			min(age) FILTER (WHERE running * 2 >= total)
+		FROM cumulative
+		GROUP BY nationality
+		ORDER BY sum(n) DESC, nationality`, argN+1), append(args, opts.TopN)...)
+	if err != nil {
+		return stats, fmt.Errorf("failed to compute age by nationality: %w", err)
+	}
+	for rows.Next() {
+		var a NationalityAge
+		if err := rows.Scan(&a.Nationality, &a.Count, &a.MeanAge, &a.MedianAge); err != nil {
+			rows.Close()
+			return stats, fmt.Errorf("failed to scan age by nationality: %w", err)
+		}
+		stats.AgeByNationality = append(stats.AgeByNationality, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return stats, fmt.Errorf("failed to compute age by nationality: %w", err)
+	}
+
+	return stats, nil
+}
+
+func queryBuckets(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]StatsBucket, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stats: %w", err)
+	}
+	defer rows.Close()
+
+	buckets := []StatsBucket{}
+	for rows.Next() {
+		var b StatsBucket
+		if err := rows.Scan(&b.Key, &b.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan stats: %w", err)
+		}
+		buckets = append(buckets, b)
+	}
+	return buckets, rows.Err()
+}
+
+// RefreshStatsView rebuilds em_people_stats_mv without blocking readers.
+func (s *PostgresStorage) RefreshStatsView() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	if _, err := s.db.ExecContext(ctx, `REFRESH MATERIALIZED VIEW CONCURRENTLY em_people_stats_mv`); err != nil {
+		return fmt.Errorf("failed to refresh stats view: %w", err)
+	}
+	return nil
+}
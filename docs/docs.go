@@ -15,9 +15,38 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/countries": {
+            "get": {
+                "description": "Страны ISO 3166-1, на которые ссылается национальность: коды alpha-2, alpha-3, числовой код и названия на английском и русском",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "countries"
+                ],
+                "summary": "Справочник стран",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/db.Country"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
         "/people": {
             "get": {
-                "description": "Получить пагинированный список людей с возможностью фильтрации по различным параметрам",
+                "description": "Получить пагинированный список людей с возможностью фильтрации по различным параметрам.\nПоддерживаются постраничный режим (page/entries) и курсорный режим (after/before/limit) со ссылками next/prev",
                 "consumes": [
                     "application/json"
                 ],
@@ -55,16 +84,35 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Фильтрация по национальности",
+                        "description": "Фильтрация по национальности (точное совпадение)",
                         "name": "nationality",
                         "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "Фильтрация по полу",
+                        "description": "Фильтрация по полу (точное совпадение)",
                         "name": "gender",
                         "in": "query"
                     },
+                    {
+                        "type": "string",
+                        "description": "Типизированный фильтр, например filter[age][gte]=18, filter[nationality][in]=RU,UA. Операторы: eq, neq, in, prefix, contains, gt, gte, lt, lte, is_null. is_null — только для patronymic, nationality (пустое значение) и enriched_at; имена сравниваются без учета регистра, пробелов и Ё/Е",
+                        "name": "filter[field][op]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Нечеткий поиск по ФИО (триграммы и фонетический ключ); результаты ранжируются по score",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "surname,-age,id",
+                        "description": "Сортировка: поля через запятую, '-' перед полем — по убыванию (id, name, surname, patronymic, age, gender, nationality, updated_at)",
+                        "name": "sort",
+                        "in": "query"
+                    },
                     {
                         "type": "integer",
                         "default": 1,
@@ -78,11 +126,63 @@ const docTemplate = `{
                         "description": "Количество записей на странице (по умолчанию: 10)",
                         "name": "entries",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Курсор: записи после данной позиции (включает курсорную пагинацию)",
+                        "name": "after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Курсор: записи до данной позиции (включает курсорную пагинацию)",
+                        "name": "before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Размер страницы в курсорном режиме (по умолчанию: 10)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "none",
+                            "exact",
+                            "estimate"
+                        ],
+                        "type": "string",
+                        "description": "Подсчет общего количества в курсорном режиме",
+                        "name": "count",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-05-01T00:00:00Z",
+                        "description": "Показать записи такими, какими они были в указанный момент (RFC 3339), по истории изменений; несовместим с q",
+                        "name": "as_of",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "nationality"
+                        ],
+                        "type": "string",
+                        "description": "Развернуть поля: nationality — объект страны вместо кода",
+                        "name": "expand",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Показать также удаленные записи (только для администраторов с заголовком X-Admin-Token)",
+                        "name": "include_deleted",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "В курсорном режиме (after/before/limit) возвращается CursorPaginatedResults",
                         "schema": {
                             "$ref": "#/definitions/api.PaginatedFilteredResults"
                         }
@@ -90,19 +190,25 @@ const docTemplate = `{
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/api.ApiError"
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/api.ApiError"
+                            "$ref": "#/definitions/api.Problem"
                         }
                     }
                 }
             },
             "post": {
-                "description": "Создание новой записи о человеке с автоматическим обогащением данных из внешних API",
+                "description": "Создание новой записи о человеке с автоматическим обогащением данных из внешних API.\nПо умолчанию запись создается всегда. С параметром on_duplicate=reject или return перед созданием ищутся вероятные дубликаты",
                 "consumes": [
                     "application/json"
                 ],
@@ -122,34 +228,148 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/api.PersonReq"
                         }
+                    },
+                    {
+                        "enum": [
+                            "reject",
+                            "return",
+                            "create"
+                        ],
+                        "type": "string",
+                        "default": "create",
+                        "description": "reject — 409 со списком кандидатов, return — вернуть существующую запись, create — создать без проверки",
+                        "name": "on_duplicate",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Найден дубликат при on_duplicate=return",
+                        "schema": {
+                            "$ref": "#/definitions/db.Person"
+                        }
+                    },
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/db.Person"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "ETag созданной записи"
+                            },
+                            "Location": {
+                                "type": "string",
+                                "description": "/people/{id} созданной записи"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "502": {
+                        "description": "Внешние API недоступны или не вернули возраст, пол или национальность",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Помечает удаленными все записи, подходящие под фильтры списка, в одной транзакции.\nСначала нужно выполнить запрос с dry_run=true и получить количество затрагиваемых записей (matched), затем повторить его с expected_count=matched. Затрагивается не более 1000 записей",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "people"
+                ],
+                "summary": "Массовое удаление по фильтру",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Типизированный фильтр, как в GET /people",
+                        "name": "filter[field][op]",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Только показать затрагиваемые записи",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Количество записей из dry_run; обязательно без dry_run",
+                        "name": "expected_count",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.SuccessResponse"
+                            "$ref": "#/definitions/api.BulkReport"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/api.ApiError"
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Количество записей изменилось после dry_run или запись изменилась",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "Не передан expected_count",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/api.ApiError"
+                            "$ref": "#/definitions/api.Problem"
                         }
                     }
                 }
-            }
-        },
-        "/people/enrich/{id}": {
-            "put": {
-                "description": "Обновление записи о человеке с возможным обогащением данных в случае изменения имени",
+            },
+            "patch": {
+                "description": "Применяет JSON Merge Patch ко всем записям, подходящим под фильтры списка (filter[field][op], fname, surname и т.д.), в одной транзакции.\nСначала нужно выполнить запрос с dry_run=true и получить количество затрагиваемых записей (matched), затем повторить его с expected_count=matched. Затрагивается не более 1000 записей",
                 "consumes": [
+                    "application/merge-patch+json",
                     "application/json"
                 ],
                 "produces": [
@@ -158,22 +378,34 @@ const docTemplate = `{
                 "tags": [
                     "people"
                 ],
-                "summary": "Обновление данных человека с обогащением",
+                "summary": "Массовое изменение по фильтру",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "ID человека",
-                        "name": "id",
-                        "in": "path",
+                        "type": "string",
+                        "description": "Типизированный фильтр, как в GET /people",
+                        "name": "filter[field][op]",
+                        "in": "query",
                         "required": true
                     },
                     {
-                        "description": "Данные о человеке",
+                        "type": "boolean",
+                        "description": "Только показать затрагиваемые записи",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Количество записей из dry_run; обязательно без dry_run",
+                        "name": "expected_count",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Изменяемые поля",
                         "name": "person",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/api.PersonReq"
+                            "$ref": "#/definitions/api.PersonMergePatch"
                         }
                     }
                 ],
@@ -181,33 +413,57 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.SuccessResponse"
+                            "$ref": "#/definitions/api.BulkReport"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/api.ApiError"
+                            "$ref": "#/definitions/api.Problem"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "409": {
+                        "description": "Количество записей изменилось после dry_run или запись изменилась",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/api.BulkReport"
+                        }
+                    },
+                    "428": {
+                        "description": "Не передан expected_count",
                         "schema": {
-                            "$ref": "#/definitions/api.ApiError"
+                            "$ref": "#/definitions/api.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/api.ApiError"
+                            "$ref": "#/definitions/api.Problem"
                         }
                     }
                 }
             }
         },
-        "/people/{id}": {
-            "delete": {
-                "description": "Удаление записи о человеке по ID",
+        "/people/bulk": {
+            "post": {
+                "description": "Выполняет список операций create, patch (JSON Merge Patch) и delete в одной транзакции: при ошибке любой операции не применяется ни одна. Результат — отчет по каждой операции.\nПри dry_run=true операции выполняются и откатываются, отчет показывает, что было бы сделано. Не более 1000 операций, каждый id — не более одного раза",
                 "consumes": [
                     "application/json"
                 ],
@@ -217,45 +473,73 @@ const docTemplate = `{
                 "tags": [
                     "people"
                 ],
-                "summary": "Удаление человека",
+                "summary": "Массовые операции",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "ID человека",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Операции",
+                        "name": "operations",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.BulkRequest"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Только показать результат, не сохраняя изменения",
+                        "name": "dry_run",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.SuccessResponse"
+                            "$ref": "#/definitions/api.BulkReport"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/api.ApiError"
+                            "$ref": "#/definitions/api.Problem"
                         }
                     },
                     "404": {
-                        "description": "Not Found",
+                        "description": "Запись не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/api.BulkReport"
+                        }
+                    },
+                    "409": {
+                        "description": "Запись изменилась",
+                        "schema": {
+                            "$ref": "#/definitions/api.BulkReport"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
                         "schema": {
-                            "$ref": "#/definitions/api.ApiError"
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/api.BulkReport"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/api.ApiError"
+                            "$ref": "#/definitions/api.Problem"
                         }
                     }
                 }
-            },
-            "patch": {
-                "description": "Частичное обновление записи о человеке (без обогащения данных)",
+            }
+        },
+        "/people/enrich/{id}": {
+            "put": {
+                "description": "Обновление записи о человеке с повторным обогащением данных в случае изменения имени. Не переданные поля сохраняют текущие значения; имена сравниваются в нормализованном виде, так что \"ivan\" для \"Ivan\" ничего не меняет\nЕсли меняются только фамилия или отчество, они записываются без запросов к внешним API\nЗапись обновляется, только если она не изменилась, пока шли запросы к внешним API; иначе возвращается 409",
                 "consumes": [
                     "application/json"
                 ],
@@ -265,7 +549,7 @@ const docTemplate = `{
                 "tags": [
                     "people"
                 ],
-                "summary": "Обновление данных человека без обогащения",
+                "summary": "Обновление данных человека с обогащением",
                 "parameters": [
                     {
                         "type": "integer",
@@ -275,89 +559,1216 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "description": "Частичные данные человека",
+                        "description": "Данные о человеке",
                         "name": "person",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/api.PersonEnriched"
+                            "$ref": "#/definitions/api.PersonReq"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag версии, на которой основано изменение; при несовпадении — 412",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.SuccessResponse"
+                            "$ref": "#/definitions/db.Person"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/api.ApiError"
+                            "$ref": "#/definitions/api.Problem"
                         }
                     },
                     "404": {
                         "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/api.ApiError"
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Запись изменилась во время обновления",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Запись изменилась: текущее представление",
+                        "schema": {
+                            "$ref": "#/definitions/db.Person"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "If-Match обязателен (REQUIRE_IF_MATCH=true)",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/api.ApiError"
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
                         }
-                    }
-                }
-            }
-        }
-    },
-    "definitions": {
-        "api.ApiError": {
-            "type": "object",
-            "properties": {
-                "error": {
-                    "type": "string",
-                    "example": "error message"
-                }
-            }
-        },
-        "api.PaginatedFilteredResults": {
-            "type": "object",
-            "properties": {
-                "entries_per_page": {
-                    "type": "integer"
-                },
-                "entries_total": {
-                    "type": "integer"
-                },
-                "page": {
-                    "type": "integer"
-                },
-                "pages_total": {
-                    "type": "integer"
-                },
-                "people": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/db.Person"
                     }
                 }
             }
         },
-        "api.PersonEnriched": {
-            "type": "object",
-            "properties": {
-                "age": {
-                    "type": "integer"
-                },
-                "gender": {
-                    "type": "string"
-                },
-                "name": {
-                    "type": "string"
-                },
+        "/people/export": {
+            "get": {
+                "description": "Выгрузка всех людей, подходящих под фильтры списка, в CSV, NDJSON или XLSX. Формат выбирается параметром format или заголовком Accept. Данные читаются курсором и отдаются потоком",
+                "produces": [
+                    "text/csv",
+                    "application/x-ndjson",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+                ],
+                "tags": [
+                    "people"
+                ],
+                "summary": "Экспорт людей",
+                "parameters": [
+                    {
+                        "enum": [
+                            "csv",
+                            "ndjson",
+                            "xlsx"
+                        ],
+                        "type": "string",
+                        "description": "Формат выгрузки",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Список колонок через запятую (по умолчанию все)",
+                        "name": "columns",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": true,
+                        "description": "Добавлять строку заголовка для csv и xlsx (по умолчанию: true)",
+                        "name": "header",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Фильтрация по имени (частичное совпадение)",
+                        "name": "fname",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Фильтрация по фамилии (частичное совпадение)",
+                        "name": "surname",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Фильтрация по отчество",
+                        "name": "patronymic",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Фильтрация по возрасту",
+                        "name": "age",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Фильтрация по национальности (точное совпадение)",
+                        "name": "nationality",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Фильтрация по полу (точное совпадение)",
+                        "name": "gender",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Типизированный фильтр, как в GET /people",
+                        "name": "filter[field][op]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Показать также удаленные записи (только для администраторов с заголовком X-Admin-Token)",
+                        "name": "include_deleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/people/import": {
+            "post": {
+                "description": "Импорт людей из CSV (заголовок name,surname,patronymic) или NDJSON (по одному PersonReq на строку). Каждая строка валидируется и обогащается, результат — отчет по строкам. При atomic=true импорт выполняется целиком или не выполняется вовсе",
+                "consumes": [
+                    "text/csv",
+                    "application/x-ndjson"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "people"
+                ],
+                "summary": "Массовый импорт людей",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Все или ничего: при любой ошибке ни одна запись не создается",
+                        "name": "atomic",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ImportReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/api.ImportReport"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/people/merge": {
+            "post": {
+                "description": "Объединение одной или нескольких записей (victim_ids) с основной записью (survivor_id) в одной транзакции.\nДля каждого поля (name, surname, patronymic, age, gender, nationality) можно выбрать: survivor — оставить значение основной записи (по умолчанию), victim — взять значение из записи from, re_enrich — заново обогатить (только age, gender, nationality).\nСлияние записывается в историю, а объединенные ID продолжают отвечать на GET редиректом 301 на основную запись",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "people"
+                ],
+                "summary": "Слияние записей о людях",
+                "parameters": [
+                    {
+                        "description": "Параметры слияния",
+                        "name": "merge",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.MergeReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/db.Person"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/people/search": {
+            "get": {
+                "description": "Поиск по имени, фамилии и отчеству одной строкой (русская морфология и префиксы слов). Результаты ранжированы, совпадения выделены тегом \u003cmark\u003e в поле highlight",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "people"
+                ],
+                "summary": "Полнотекстовый поиск людей",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Поисковая строка",
+                        "name": "q",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Номер страницы (по умолчанию: 1)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Количество записей на странице (по умолчанию: 10)",
+                        "name": "entries",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Типизированный фильтр, как в GET /people",
+                        "name": "filter[field][op]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Показать также удаленные записи (только для администраторов с заголовком X-Admin-Token)",
+                        "name": "include_deleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.SearchResults"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/people/stats": {
+            "get": {
+                "description": "Распределения по полу, национальности (top N и \"other\"), гистограмма возрастов и средний/медианный возраст по национальностям. Учитывает те же фильтры, что и GET /people. При source=materialized читается материализованное представление (фильтры только по nationality, gender, age); оно обновляется командой go run . -refresh-stats",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "people"
+                ],
+                "summary": "Статистика по людям",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Количество национальностей в топе (по умолчанию: 10)",
+                        "name": "top",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Ширина интервала гистограммы возрастов (по умолчанию: 10)",
+                        "name": "bucket",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "live",
+                            "materialized"
+                        ],
+                        "type": "string",
+                        "default": "live",
+                        "description": "Источник данных",
+                        "name": "source",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Типизированный фильтр, как в GET /people",
+                        "name": "filter[field][op]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Показать также удаленные записи (только для администраторов с заголовком X-Admin-Token)",
+                        "name": "include_deleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/db.PeopleStats"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/people/{id}": {
+            "get": {
+                "description": "Получить полную запись о человеке вместе с метаданными обогащения. Поддерживает условные запросы через If-None-Match.\nДля записи, объединенной с другой, возвращается 301 с Location и Link rel=\"canonical\" на основную запись",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "people"
+                ],
+                "summary": "Получение человека по ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID человека",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag ранее полученной версии записи",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-05-01T00:00:00Z",
+                        "description": "Вернуть запись такой, какой она была в указанный момент (RFC 3339), по истории изменений",
+                        "name": "as_of",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "nationality"
+                        ],
+                        "type": "string",
+                        "description": "Развернуть поля: nationality — объект страны вместо кода",
+                        "name": "expand",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.PersonView"
+                        }
+                    },
+                    "301": {
+                        "description": "Запись объединена с другой",
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "/people/{id} основной записи"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Запись не изменилась"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Мягкое удаление записи о человеке по ID: запись исчезает из выдачи, но ее можно восстановить через POST /people/{id}/restore до истечения срока хранения",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "people"
+                ],
+                "summary": "Удаление человека",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID человека",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag версии, на которой основано изменение; при несовпадении — 412",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Запись удалена"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Запись изменилась: текущее представление",
+                        "schema": {
+                            "$ref": "#/definitions/db.Person"
+                        }
+                    },
+                    "428": {
+                        "description": "If-Match обязателен (REQUIRE_IF_MATCH=true)",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Частичное обновление записи о человеке (без обогащения данных) в формате JSON Merge Patch (RFC 7396): изменяются только переданные поля, null очищает поле.\nТакже принимается JSON Patch (RFC 6902, application/json-patch+json) с операциями replace, remove и test, например [{\"op\": \"test\", \"path\": \"/nationality\", \"value\": \"RU\"}, {\"op\": \"replace\", \"path\": \"/nationality\", \"value\": \"KZ\"}]. Операции применяются атомарно; если test не выполняется, возвращается 409 и запись не изменяется.\nПолучившаяся запись проверяется целиком; при ошибках возвращается 422",
+                "consumes": [
+                    "application/merge-patch+json",
+                    "application/json-patch+json",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "people"
+                ],
+                "summary": "Обновление данных человека без обогащения",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID человека",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Изменяемые поля (для application/json-patch+json — массив PatchOperation)",
+                        "name": "person",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.PersonMergePatch"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag версии, на которой основано изменение; при несовпадении — 412",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/db.Person"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Не выполнена операция test или запись изменилась во время обновления",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Запись изменилась: текущее представление",
+                        "schema": {
+                            "$ref": "#/definitions/db.Person"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "If-Match обязателен (REQUIRE_IF_MATCH=true)",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/people/{id}/duplicates": {
+            "get": {
+                "description": "Поиск записей, похожих на данного человека: совпадение нормализованного ФИО или высокая схожесть полного имени",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "people"
+                ],
+                "summary": "Вероятные дубликаты человека",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID человека",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/db.DuplicateCandidate"
+                            }
+                        }
+                    },
+                    "301": {
+                        "description": "Запись объединена с другой"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/people/{id}/history": {
+            "get": {
+                "description": "Журнал изменений записи: старое и новое значение каждого поля, автор (заголовок X-Actor), источник (create, patch, enrichment, merge, import, delete, restore, purge, bulk), ID запроса и время.\nЗаписи журнала не изменяются и сохраняются после слияния и удаления",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "people"
+                ],
+                "summary": "История изменений человека",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID человека",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Номер страницы (по умолчанию: 1)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Количество записей на странице (по умолчанию: 100)",
+                        "name": "entries",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/db.HistoryEntry"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/people/{id}/restore": {
+            "post": {
+                "description": "Отмена мягкого удаления записи о человеке. Для неудаленной записи ничего не меняет",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "people"
+                ],
+                "summary": "Восстановление удаленного человека",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID человека",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/db.Person"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/reports/crosstab": {
+            "get": {
+                "description": "Матрица rows × cols с мерой count или avg_age по людям, подходящим под фильтры GET /people. Количество строк и столбцов ограничено. При format=csv отдается CSV",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "text/csv"
+                ],
+                "tags": [
+                    "reports"
+                ],
+                "summary": "Сводная таблица (crosstab)",
+                "parameters": [
+                    {
+                        "enum": [
+                            "nationality",
+                            "gender",
+                            "age_band"
+                        ],
+                        "type": "string",
+                        "description": "Измерение строк",
+                        "name": "rows",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "nationality",
+                            "gender",
+                            "age_band"
+                        ],
+                        "type": "string",
+                        "description": "Измерение столбцов",
+                        "name": "cols",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "count",
+                            "avg_age"
+                        ],
+                        "type": "string",
+                        "default": "count",
+                        "description": "Мера",
+                        "name": "measure",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Ширина возрастной группы для age_band (по умолчанию: 10)",
+                        "name": "band",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "json",
+                            "csv"
+                        ],
+                        "type": "string",
+                        "default": "json",
+                        "description": "Формат ответа",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Типизированный фильтр, как в GET /people",
+                        "name": "filter[field][op]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Показать также удаленные записи (только для администраторов с заголовком X-Admin-Token)",
+                        "name": "include_deleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/db.Crosstab"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "api.BulkItemResult": {
+            "type": "object",
+            "properties": {
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "index": {
+                    "type": "integer"
+                },
+                "op": {
+                    "type": "string",
+                    "example": "patch"
+                },
+                "person": {
+                    "$ref": "#/definitions/db.Person"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "patched"
+                }
+            }
+        },
+        "api.BulkOperation": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer",
+                    "example": 42
+                },
+                "op": {
+                    "type": "string",
+                    "enum": [
+                        "create",
+                        "patch",
+                        "delete"
+                    ],
+                    "example": "patch"
+                },
+                "patch": {
+                    "type": "object"
+                },
+                "person": {
+                    "$ref": "#/definitions/api.PersonReq"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.BulkReport": {
+            "type": "object",
+            "properties": {
+                "committed": {
+                    "type": "boolean"
+                },
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.BulkItemResult"
+                    }
+                },
+                "matched": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.BulkRequest": {
+            "type": "object",
+            "properties": {
+                "operations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.BulkOperation"
+                    }
+                }
+            }
+        },
+        "api.FieldError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string",
+                    "example": "surname"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "must be at most 100 characters"
+                }
+            }
+        },
+        "api.ImportReport": {
+            "type": "object",
+            "properties": {
+                "atomic": {
+                    "type": "boolean"
+                },
+                "committed": {
+                    "type": "boolean"
+                },
+                "created": {
+                    "type": "integer"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "rows": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.ImportRowResult"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.ImportRowResult": {
+            "type": "object",
+            "properties": {
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "row": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "created"
+                }
+            }
+        },
+        "api.MergeReq": {
+            "type": "object",
+            "properties": {
+                "fields": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/db.MergeField"
+                    }
+                },
+                "survivor_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "victim_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        2,
+                        3
+                    ]
+                }
+            }
+        },
+        "api.PaginatedFilteredResults": {
+            "type": "object",
+            "properties": {
+                "entries_per_page": {
+                    "type": "integer"
+                },
+                "entries_total": {
+                    "type": "integer"
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "pages_total": {
+                    "type": "integer"
+                },
+                "people": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.PersonView"
+                    }
+                }
+            }
+        },
+        "api.PersonMergePatch": {
+            "type": "object",
+            "properties": {
+                "age": {
+                    "type": "integer"
+                },
+                "gender": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
                 "nationality": {
                     "type": "string"
                 },
@@ -369,26 +1780,414 @@ const docTemplate = `{
                 }
             }
         },
-        "api.PersonReq": {
+        "api.PersonReq": {
+            "type": "object",
+            "required": [
+                "name",
+                "surname"
+            ],
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "maxLength": 50
+                },
+                "patronymic": {
+                    "type": "string",
+                    "maxLength": 100
+                },
+                "surname": {
+                    "type": "string",
+                    "maxLength": 100
+                }
+            }
+        },
+        "api.PersonView": {
+            "type": "object",
+            "properties": {
+                "age": {
+                    "type": "integer"
+                },
+                "deleted_at": {
+                    "type": "string"
+                },
+                "enriched_at": {
+                    "type": "string"
+                },
+                "gender": {
+                    "type": "string"
+                },
+                "gender_probability": {
+                    "type": "number"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "nationality": {
+                    "type": "string",
+                    "example": "RU"
+                },
+                "nationality_probability": {
+                    "type": "number"
+                },
+                "patronymic": {
+                    "type": "string"
+                },
+                "score": {
+                    "type": "number"
+                },
+                "surname": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.Problem": {
+            "type": "object",
+            "properties": {
+                "candidates": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "code": {
+                    "type": "string",
+                    "example": "not_found"
+                },
+                "detail": {
+                    "type": "string",
+                    "example": "person with id 42 not found"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.FieldError"
+                    }
+                },
+                "instance": {
+                    "type": "string",
+                    "example": "/people/42"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 404
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Not Found"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "about:blank"
+                }
+            }
+        },
+        "api.SearchResults": {
+            "type": "object",
+            "properties": {
+                "entries_per_page": {
+                    "type": "integer"
+                },
+                "entries_total": {
+                    "type": "integer"
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "pages_total": {
+                    "type": "integer"
+                },
+                "query": {
+                    "type": "string"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/db.PersonSearchHit"
+                    }
+                }
+            }
+        },
+        "db.AgeBucket": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "from": {
+                    "type": "integer"
+                },
+                "to": {
+                    "type": "integer"
+                }
+            }
+        },
+        "db.Country": {
             "type": "object",
             "properties": {
+                "alpha2": {
+                    "type": "string",
+                    "example": "RU"
+                },
+                "alpha3": {
+                    "type": "string",
+                    "example": "RUS"
+                },
+                "name_en": {
+                    "type": "string",
+                    "example": "Russia"
+                },
+                "name_ru": {
+                    "type": "string",
+                    "example": "Россия"
+                },
+                "numeric": {
+                    "type": "string",
+                    "example": "643"
+                }
+            }
+        },
+        "db.Crosstab": {
+            "type": "object",
+            "properties": {
+                "col_keys": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "col_totals": {
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "cols": {
+                    "type": "string"
+                },
+                "grand_total": {
+                    "type": "number"
+                },
+                "measure": {
+                    "$ref": "#/definitions/db.CrosstabMeasure"
+                },
+                "row_keys": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "row_totals": {
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "rows": {
+                    "type": "string"
+                },
+                "values": {
+                    "type": "array",
+                    "items": {
+                        "type": "array",
+                        "items": {
+                            "type": "number"
+                        }
+                    }
+                }
+            }
+        },
+        "db.CrosstabMeasure": {
+            "type": "string",
+            "enum": [
+                "count",
+                "avg_age"
+            ],
+            "x-enum-varnames": [
+                "MeasureCount",
+                "MeasureAvgAge"
+            ]
+        },
+        "db.DuplicateCandidate": {
+            "type": "object",
+            "properties": {
+                "age": {
+                    "type": "integer"
+                },
+                "deleted_at": {
+                    "type": "string"
+                },
+                "enriched_at": {
+                    "type": "string"
+                },
+                "gender": {
+                    "type": "string"
+                },
+                "gender_probability": {
+                    "type": "number"
+                },
+                "id": {
+                    "type": "integer"
+                },
                 "name": {
                     "type": "string"
                 },
+                "nationality": {
+                    "type": "string"
+                },
+                "nationality_probability": {
+                    "type": "number"
+                },
                 "patronymic": {
                     "type": "string"
                 },
+                "score": {
+                    "type": "number"
+                },
+                "similarity": {
+                    "type": "number"
+                },
                 "surname": {
                     "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "integer"
                 }
             }
         },
-        "api.SuccessResponse": {
+        "db.HistoryEntry": {
             "type": "object",
             "properties": {
-                "status": {
+                "actor": {
+                    "type": "string"
+                },
+                "changed_at": {
+                    "type": "string"
+                },
+                "field": {
+                    "type": "string",
+                    "example": "surname"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "new_value": {
+                    "type": "string"
+                },
+                "old_value": {
+                    "type": "string"
+                },
+                "person_id": {
+                    "type": "integer"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "source": {
                     "type": "string",
-                    "example": "success"
+                    "example": "patch"
+                }
+            }
+        },
+        "db.MergeChoice": {
+            "type": "string",
+            "enum": [
+                "survivor",
+                "victim",
+                "re_enrich"
+            ],
+            "x-enum-varnames": [
+                "MergeKeepSurvivor",
+                "MergeTakeVictim",
+                "MergeReEnrich"
+            ]
+        },
+        "db.MergeField": {
+            "type": "object",
+            "properties": {
+                "choice": {
+                    "enum": [
+                        "survivor",
+                        "victim",
+                        "re_enrich"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/db.MergeChoice"
+                        }
+                    ]
+                },
+                "from": {
+                    "type": "integer"
+                }
+            }
+        },
+        "db.NationalityAge": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "mean_age": {
+                    "type": "number"
+                },
+                "median_age": {
+                    "type": "integer"
+                },
+                "nationality": {
+                    "type": "string"
+                }
+            }
+        },
+        "db.PeopleStats": {
+            "type": "object",
+            "properties": {
+                "age_by_nationality": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/db.NationalityAge"
+                    }
+                },
+                "age_histogram": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/db.AgeBucket"
+                    }
+                },
+                "by_gender": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/db.StatsBucket"
+                    }
+                },
+                "by_nationality": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/db.StatsBucket"
+                    }
+                },
+                "refreshed_at": {
+                    "type": "string"
+                },
+                "source": {
+                    "$ref": "#/definitions/db.StatsSource"
+                },
+                "total": {
+                    "type": "integer"
                 }
             }
         },
@@ -398,9 +2197,68 @@ const docTemplate = `{
                 "age": {
                     "type": "integer"
                 },
+                "deleted_at": {
+                    "type": "string"
+                },
+                "enriched_at": {
+                    "type": "string"
+                },
+                "gender": {
+                    "type": "string"
+                },
+                "gender_probability": {
+                    "type": "number"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "nationality": {
+                    "type": "string"
+                },
+                "nationality_probability": {
+                    "type": "number"
+                },
+                "patronymic": {
+                    "type": "string"
+                },
+                "score": {
+                    "type": "number"
+                },
+                "surname": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "db.PersonSearchHit": {
+            "type": "object",
+            "properties": {
+                "age": {
+                    "type": "integer"
+                },
+                "deleted_at": {
+                    "type": "string"
+                },
+                "enriched_at": {
+                    "type": "string"
+                },
                 "gender": {
                     "type": "string"
                 },
+                "gender_probability": {
+                    "type": "number"
+                },
+                "highlight": {
+                    "type": "string"
+                },
                 "id": {
                     "type": "integer"
                 },
@@ -410,13 +2268,47 @@ const docTemplate = `{
                 "nationality": {
                     "type": "string"
                 },
+                "nationality_probability": {
+                    "type": "number"
+                },
                 "patronymic": {
                     "type": "string"
                 },
+                "score": {
+                    "type": "number"
+                },
                 "surname": {
                     "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "db.StatsBucket": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "key": {
+                    "type": "string"
                 }
             }
+        },
+        "db.StatsSource": {
+            "type": "string",
+            "enum": [
+                "live",
+                "materialized"
+            ],
+            "x-enum-varnames": [
+                "StatsLive",
+                "StatsMaterialized"
+            ]
         }
     }
 }`
@@ -14,7 +14,11 @@ import (
 
 func main() {
 	var envPath string
+	var refreshStats bool
+	var purgeDeleted bool
 	flag.StringVar(&envPath, "envPath", "./.env", "path to .env file (default: ./.env)")
+	flag.BoolVar(&refreshStats, "refresh-stats", false, "refresh the people stats materialized view and exit")
+	flag.BoolVar(&purgeDeleted, "purge-deleted", false, "permanently remove people deleted longer than DELETED_RETENTION ago and exit; the server also does this every PURGE_INTERVAL")
 	flag.Parse()
 
 	if err := godotenv.Load(envPath); err != nil {
@@ -26,6 +30,27 @@ func main() {
 		log.Fatalf("Failed to connect to DB: %v", err)
 	}
 
+	if refreshStats {
+		if err := pgStore.RefreshStatsView(); err != nil {
+			log.Fatalf("Failed to refresh stats: %v", err)
+		}
+		log.Println("stats view refreshed")
+		return
+	}
+
+	if purgeDeleted {
+		retention, err := db.DeletedRetention()
+		if err != nil {
+			log.Fatal(err)
+		}
+		n, err := pgStore.PurgeDeleted(retention)
+		if err != nil {
+			log.Fatalf("Failed to purge deleted people: %v", err)
+		}
+		log.Printf("purged %d deleted people", n)
+		return
+	}
+
 	server := api.NewAPIServer(":8080", *pgStore)
 
 	server.RunAPIServer()